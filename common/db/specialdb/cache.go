@@ -0,0 +1,181 @@
+package specialdb
+
+/**
+  类型化缓存：GetAs/SetAs/MGet 按 Codec 编解码，键按命名空间（租户、服务）隔离
+    cache := redisTemplate.WithNamespace("crm", tenantID)
+    err := specialdb.SetAs(ctx, cache, "member:"+id, member, 10*time.Minute)
+    member, err := specialdb.GetAs[Member](ctx, cache, "member:"+id)
+    if errors.Is(err, specialdb.ErrNotFound) { ... }
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ErrNotFound 缓存不存在（errors.Is(err, redis.ErrNil) 同样成立，兼容原有判断）
+var ErrNotFound error = notFoundError{}
+
+type notFoundError struct{}
+
+func (notFoundError) Error() string {
+	return "redis: 缓存不存在"
+}
+
+func (notFoundError) Is(target error) bool {
+	return target == redis.ErrNil
+}
+
+// RedisError redis 命令执行错误
+type RedisError struct {
+	Op  string // 命令或操作
+	Key string
+	Err error
+}
+
+func (e *RedisError) Error() string {
+	return fmt.Sprintf("redis %s %s: %v", e.Op, e.Key, e.Err)
+}
+
+func (e *RedisError) Unwrap() error {
+	return e.Err
+}
+
+// wrapErr 包装命令错误，键不存在时返回 ErrNotFound
+func wrapErr(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, redis.ErrNil) {
+		return ErrNotFound
+	}
+	var redisErr *RedisError
+	if errors.As(err, &redisErr) {
+		return err
+	}
+	return &RedisError{Op: op, Key: key, Err: err}
+}
+
+// WithNamespace 返回使用指定命名空间的 RedisTemplate（共用连接池），键自动加上 "命名空间:" 前缀，可多级嵌套
+func (s *RedisTemplate) WithNamespace(namespace ...string) *RedisTemplate {
+	clone := *s
+	parts := make([]string, 0, len(namespace)+1)
+	if s.Namespace != "" {
+		parts = append(parts, s.Namespace)
+	}
+	for _, ns := range namespace {
+		if ns = strings.Trim(ns, ":"); ns != "" {
+			parts = append(parts, ns)
+		}
+	}
+	clone.Namespace = strings.Join(parts, ":")
+	return &clone
+}
+
+// WithCodec 返回使用指定编解码的 RedisTemplate（共用连接池）
+func (s *RedisTemplate) WithCodec(codec Codec) *RedisTemplate {
+	clone := *s
+	clone.Codec = codec
+	return &clone
+}
+
+// Key 取得加上命名空间前缀后的完整键
+func (s *RedisTemplate) Key(key string) string {
+	if s.Namespace == "" {
+		return key
+	}
+	return s.Namespace + ":" + key
+}
+
+func (s *RedisTemplate) codec() Codec {
+	if s.Codec == nil {
+		return JSONCodec{}
+	}
+	return s.Codec
+}
+
+// expireArgs 过期时间参数，ttl<=0 表示不过期；非整秒时使用毫秒
+func expireArgs(ttl time.Duration) []interface{} {
+	if ttl <= 0 {
+		return nil
+	}
+	if ttl%time.Second != 0 {
+		return []interface{}{"PX", ttl.Milliseconds()}
+	}
+	return []interface{}{"EX", int64(ttl / time.Second)}
+}
+
+// GetAs 读取缓存并解码为 T，不存在（含 Fetch 缓存的“不存在”）时返回 ErrNotFound
+func GetAs[T any](ctx context.Context, s *RedisTemplate, key string) (T, error) {
+	var value T
+	key = s.Key(key)
+	conn := s.getConn()
+	defer safeClose(conn, "GetAs")
+	data, err := redis.Bytes(conn.Do("GET", s.buildArgs(ctx, []interface{}{key})...))
+	if err != nil {
+		return value, wrapErr("GET", key, err)
+	}
+	if bytes.Equal(data, notFoundMarker) {
+		return value, ErrNotFound
+	}
+	if err = s.codec().Unmarshal(data, &value); err != nil {
+		return value, &RedisError{Op: "decode", Key: key, Err: err}
+	}
+	return value, nil
+}
+
+// SetAs 编码后写入缓存，ttl<=0 表示不过期
+func SetAs[T any](ctx context.Context, s *RedisTemplate, key string, value T, ttl time.Duration) error {
+	key = s.Key(key)
+	data, err := s.codec().Marshal(value)
+	if err != nil {
+		return &RedisError{Op: "encode", Key: key, Err: err}
+	}
+	conn := s.getConn()
+	defer safeClose(conn, "SetAs")
+	args := append([]interface{}{key, data}, expireArgs(ttl)...)
+	_, err = conn.Do("SET", s.buildArgs(ctx, args)...)
+	return wrapErr("SET", key, err)
+}
+
+// MGet 批量读取缓存，返回存在的键（不含命名空间）与值；集群模式同一节点的键一次管道读取
+func MGet[T any](ctx context.Context, s *RedisTemplate, keys ...string) (map[string]T, error) {
+	values, err := s.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]T, len(values))
+	codec := s.codec()
+	for key, data := range values {
+		if bytes.Equal(data, notFoundMarker) {
+			continue
+		}
+		var value T
+		if err = codec.Unmarshal(data, &value); err != nil {
+			return result, &RedisError{Op: "decode", Key: s.Key(key), Err: err}
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// mgetRaw 读取同一槽位的多个完整键
+func (s *RedisTemplate) mgetRaw(ctx context.Context, keys []string) ([][]byte, error) {
+	conn := s.getConn(keys...)
+	defer safeClose(conn, "MGET")
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+	values, err := redis.ByteSlices(conn.Do("MGET", s.buildArgs(ctx, args)...))
+	if err != nil {
+		return nil, &RedisError{Op: "MGET", Key: strings.Join(keys, ","), Err: err}
+	}
+	return values, nil
+}
@@ -0,0 +1,131 @@
+package specialdb
+
+/**
+  缓存编解码：JSON（默认）、msgpack、protobuf，以及 gzip 压缩包装
+*/
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec 缓存值编解码
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec JSON 编解码（默认，与 Set 写入的格式一致）
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MsgpackCodec msgpack 编解码，体积小于 JSON
+type MsgpackCodec struct{}
+
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// ProtoCodec protobuf 编解码，值必须实现 proto.Message（泛型方法使用指针类型：GetAs[*pb.Member]）
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("ProtoCodec: %T 未实现 proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal v 为 *T（T 实现 proto.Message）或 **T（GetAs[*pb.Msg] 等泛型方法传入），**T 为 nil 时自动创建
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Ptr {
+			elem := rv.Elem()
+			if elem.IsNil() {
+				elem.Set(reflect.New(elem.Type().Elem()))
+			}
+			m, ok = elem.Interface().(proto.Message)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("ProtoCodec: %T 未实现 proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// GzipCodec 对内部编解码结果进行 gzip 压缩，小于 MinSize 的值不压缩；读取时根据 gzip 头自动识别
+type GzipCodec struct {
+	Codec   Codec // 内部编解码，默认 JSONCodec
+	MinSize int   // 压缩阈值（字节），默认 1024
+}
+
+func (c GzipCodec) inner() Codec {
+	if c.Codec == nil {
+		return JSONCodec{}
+	}
+	return c.Codec
+}
+
+func (c GzipCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.inner().Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	minSize := c.MinSize
+	if minSize <= 0 {
+		minSize = 1024
+	}
+	if len(data) < minSize {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c GzipCodec) Unmarshal(data []byte, v interface{}) error {
+	if isGzip(data) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		if data, err = io.ReadAll(r); err != nil {
+			return errors.New("gzip 解压失败:" + err.Error())
+		}
+	}
+	return c.inner().Unmarshal(data, v)
+}
+
+// isGzip 是否为 gzip 数据（魔数 1f 8b）
+func isGzip(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
@@ -0,0 +1,128 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecItem struct {
+	ID   int    `json:"id" msgpack:"id"`
+	Name string `json:"name" msgpack:"name"`
+}
+
+func TestCodec(t *testing.T) {
+	item := codecItem{ID: 1, Name: strings.Repeat("会员", 300)}
+	codecs := map[string]Codec{
+		"json":    JSONCodec{},
+		"msgpack": MsgpackCodec{},
+		"gzip":    GzipCodec{},
+		"gzip-mp": GzipCodec{Codec: MsgpackCodec{}, MinSize: 16},
+	}
+	for name, codec := range codecs {
+		data, err := codec.Marshal(item)
+		if err != nil {
+			t.Fatalf("%s Marshal: %v", name, err)
+		}
+		var got codecItem
+		if err = codec.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s Unmarshal: %v", name, err)
+		}
+		if got != item {
+			t.Errorf("%s 编解码结果不一致", name)
+		}
+	}
+
+	// 超过阈值才压缩，未压缩的数据同样可以读取
+	data, _ := GzipCodec{}.Marshal(item)
+	if !isGzip(data) {
+		t.Error("超过阈值应压缩")
+	}
+	small, _ := GzipCodec{}.Marshal(codecItem{ID: 2})
+	if isGzip(small) {
+		t.Error("小于阈值不应压缩")
+	}
+	if _, err := (ProtoCodec{}).Marshal(item); err == nil {
+		t.Error("ProtoCodec 应拒绝非 proto.Message")
+	}
+}
+
+func TestProtoCodec(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	s = s.WithCodec(ProtoCodec{})
+	ctx := context.Background()
+
+	// 泛型方法的类型参数为指针类型，Unmarshal 收到 **T
+	if err := SetAs(ctx, s, "m1", wrapperspb.String("会员"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetAs[*wrapperspb.StringValue](ctx, s, "m1")
+	if err != nil || got.GetValue() != "会员" {
+		t.Fatalf("GetAs = %v, %v", got, err)
+	}
+	_ = SetAs(ctx, s, "m2", wrapperspb.String("订单"), time.Minute)
+	items, err := MGet[*wrapperspb.StringValue](ctx, s, "m1", "m2")
+	if err != nil || len(items) != 2 || items["m2"].GetValue() != "订单" {
+		t.Fatalf("MGet = %v, %v", items, err)
+	}
+	data, _ := proto.Marshal(wrapperspb.String("门店"))
+	if _, err = s.HSet("h", "f", data, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = HGetAs[*wrapperspb.StringValue](ctx, s, "h", "f"); err != nil || got.GetValue() != "门店" {
+		t.Fatalf("HGetAs = %v, %v", got, err)
+	}
+
+	// *T 同样支持
+	var value wrapperspb.StringValue
+	raw, _ := s.Get("m1", ctx)
+	if err = (ProtoCodec{}).Unmarshal(raw, &value); err != nil || value.GetValue() != "会员" {
+		t.Fatalf("Unmarshal = %v, %v", value.GetValue(), err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	s := &RedisTemplate{}
+	if s.Key("a") != "a" {
+		t.Errorf("无命名空间 Key = %s", s.Key("a"))
+	}
+	tenant := s.WithNamespace("crm", "1001:")
+	if got := tenant.Key("member:1"); got != "crm:1001:member:1" {
+		t.Errorf("Key = %s", got)
+	}
+	if got := tenant.WithNamespace("order").Key("x"); got != "crm:1001:order:x" {
+		t.Errorf("嵌套 Key = %s", got)
+	}
+	if s.Namespace != "" {
+		t.Error("WithNamespace 不应修改原对象")
+	}
+	if _, ok := tenant.WithCodec(MsgpackCodec{}).codec().(MsgpackCodec); !ok {
+		t.Error("WithCodec 未生效")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if err := wrapErr("GET", "k", redis.ErrNil); !errors.Is(err, ErrNotFound) || !errors.Is(err, redis.ErrNil) {
+		t.Errorf("未找到错误 = %v", err)
+	}
+	cause := errors.New("连接失败")
+	var redisErr *RedisError
+	if err := wrapErr("SET", "k", cause); !errors.As(err, &redisErr) || !errors.Is(err, cause) || redisErr.Op != "SET" {
+		t.Errorf("命令错误 = %v", err)
+	}
+	if args := expireArgs(1500 * time.Millisecond); args[0] != "PX" || args[1] != int64(1500) {
+		t.Errorf("expireArgs = %v", args)
+	}
+	if args := expireArgs(time.Minute); args[0] != "EX" || args[1] != int64(60) {
+		t.Errorf("expireArgs = %v", args)
+	}
+	if args := expireArgs(0); args != nil {
+		t.Errorf("expireArgs = %v", args)
+	}
+}
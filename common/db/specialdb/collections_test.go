@@ -0,0 +1,163 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHash(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	cache := s.WithNamespace("crm")
+
+	if n, err := cache.HMSet("card", map[string]interface{}{"no": "A001", "item": codecItem{ID: 1}}, ctx); err != nil || n != 2 {
+		t.Fatalf("HMSet = %d, %v", n, err)
+	}
+	if mr.HGet("crm:card", "no") != "A001" {
+		t.Fatal("键未加上命名空间")
+	}
+	if v, err := cache.HGet("card", "no", ctx); err != nil || string(v) != "A001" {
+		t.Fatalf("HGet = %s, %v", v, err)
+	}
+	if _, err := cache.HGet("card", "missing", ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("HGet 不存在 err = %v", err)
+	}
+	if item, err := HGetAs[codecItem](ctx, cache, "card", "item"); err != nil || item.ID != 1 {
+		t.Fatalf("HGetAs = %v, %v", item, err)
+	}
+	if n, err := cache.HIncrBy("card", "points", 10, ctx); err != nil || n != 10 {
+		t.Fatalf("HIncrBy = %d, %v", n, err)
+	}
+	values, err := cache.HMGet("card", []string{"no", "points", "missing"}, ctx)
+	if err != nil || len(values) != 2 || string(values["points"]) != "10" {
+		t.Fatalf("HMGet = %v, %v", values, err)
+	}
+	all, err := cache.HGetAll("card", ctx)
+	if err != nil || len(all) != 3 || string(all["no"]) != "A001" {
+		t.Fatalf("HGetAll = %v, %v", all, err)
+	}
+	if ok, _ := cache.HSetNX("card", "no", "B", ctx); ok {
+		t.Fatal("HSetNX 不应覆盖已有字段")
+	}
+	if n, _ := cache.HDel("card", []string{"no", "missing"}, ctx); n != 1 {
+		t.Fatalf("HDel = %d", n)
+	}
+	if ok, _ := cache.HExists("card", "no", ctx); ok {
+		t.Fatal("HDel 后字段仍存在")
+	}
+	if n, _ := cache.HLen("card", ctx); n != 2 {
+		t.Fatalf("HLen = %d", n)
+	}
+	if all, err = cache.HGetAll("none", ctx); err != nil || len(all) != 0 {
+		t.Fatalf("HGetAll 不存在 = %v, %v", all, err)
+	}
+}
+
+func TestList(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx := context.Background()
+	cache := s.WithNamespace("crm")
+
+	if n, err := cache.RPush("queue", []interface{}{"a", "b", "c"}, ctx); err != nil || n != 3 {
+		t.Fatalf("RPush = %d, %v", n, err)
+	}
+	_, _ = cache.LPush("queue", []interface{}{"z"}, ctx)
+	items, err := cache.LRange("queue", 0, -1, ctx)
+	if err != nil || len(items) != 4 || string(items[0]) != "z" {
+		t.Fatalf("LRange = %q, %v", items, err)
+	}
+	if v, _ := cache.LPop("queue", ctx); string(v) != "z" {
+		t.Fatalf("LPop = %s", v)
+	}
+	if v, _ := cache.RPop("queue", ctx); string(v) != "c" {
+		t.Fatalf("RPop = %s", v)
+	}
+	if n, _ := cache.LRem("queue", 0, "a", ctx); n != 1 {
+		t.Fatalf("LRem = %d", n)
+	}
+	key, v, err := cache.BLPop([]string{"empty", "queue"}, time.Second, ctx)
+	if err != nil || key != "queue" || string(v) != "b" {
+		t.Fatalf("BLPop = %s %s, %v", key, v, err)
+	}
+	if _, err = cache.LPop("queue", ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("LPop 空列表 err = %v", err)
+	}
+	_, _ = cache.RPush("queue", []interface{}{1, 2, 3}, ctx)
+	if err = cache.LTrim("queue", 0, 1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := cache.LLen("queue", ctx); n != 2 {
+		t.Fatalf("LLen = %d", n)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx := context.Background()
+
+	if n, err := s.SAdd("tags", []interface{}{"vip", "new", "vip"}, ctx); err != nil || n != 2 {
+		t.Fatalf("SAdd = %d, %v", n, err)
+	}
+	if ok, _ := s.SIsMember("tags", "vip", ctx); !ok {
+		t.Fatal("SIsMember = false")
+	}
+	members, _ := s.SMembers("tags", ctx)
+	sort.Strings(members)
+	if len(members) != 2 || members[0] != "new" {
+		t.Fatalf("SMembers = %v", members)
+	}
+	var scanned []string
+	if err := s.SScan("tags", "", func(m []string) error {
+		scanned = append(scanned, m...)
+		return nil
+	}, ctx); err != nil || len(scanned) != 2 {
+		t.Fatalf("SScan = %v, %v", scanned, err)
+	}
+	_, _ = s.SRem("tags", []interface{}{"new"}, ctx)
+	if n, _ := s.SCard("tags", ctx); n != 1 {
+		t.Fatalf("SCard = %d", n)
+	}
+}
+
+func TestZSet(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx := context.Background()
+
+	n, err := s.ZAdd("rank", []ZMember{{Member: "a", Score: 10}, {Member: "b", Score: 20.5}, {Member: "c", Score: 30}}, ctx)
+	if err != nil || n != 3 {
+		t.Fatalf("ZAdd = %d, %v", n, err)
+	}
+	if score, _ := s.ZIncrBy("rank", "a", 50, ctx); score != 60 {
+		t.Fatalf("ZIncrBy = %v", score)
+	}
+	members, err := s.ZRangeByScore("rank", "(10", "+inf", 0, 1, ctx)
+	if err != nil || len(members) != 1 || members[0] != (ZMember{Member: "b", Score: 20.5}) {
+		t.Fatalf("ZRangeByScore = %v, %v", members, err)
+	}
+	top, _ := s.ZRevRange("rank", 0, 0, ctx)
+	if len(top) != 1 || top[0].Member != "a" {
+		t.Fatalf("ZRevRange = %v", top)
+	}
+	if rank, _ := s.ZRevRank("rank", "c", ctx); rank != 1 {
+		t.Fatalf("ZRevRank = %d", rank)
+	}
+	if _, err = s.ZScore("rank", "x", ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ZScore 不存在 err = %v", err)
+	}
+	if _, err = s.ZRank("rank", "x", ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ZRank 不存在 err = %v", err)
+	}
+	if n, _ := s.ZCount("rank", "-inf", FormatScore(30), ctx); n != 2 {
+		t.Fatalf("ZCount = %d", n)
+	}
+	if n, _ := s.ZRemRangeByScore("rank", "-inf", "25", ctx); n != 1 {
+		t.Fatalf("ZRemRangeByScore = %d", n)
+	}
+	_, _ = s.ZRem("rank", []string{"c"}, ctx)
+	if n, _ := s.ZCard("rank", ctx); n != 1 {
+		t.Fatalf("ZCard = %d", n)
+	}
+}
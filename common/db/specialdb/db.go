@@ -2,6 +2,7 @@ package specialdb
 
 import (
 	"errors"
+	"fmt"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlserver"
@@ -72,29 +73,27 @@ func ConnDB(config DbConfig) (*gorm.DB, error) {
 	return db, nil
 }
 
-// Transaction 统一事务
+// Transaction 统一事务，tx 需由调用方开启
+//
+// Deprecated: 使用 WithTx，支持 context、嵌套事务与死锁重试
 func Transaction(tx *gorm.DB, fn func() error) (err error) {
 	//开启事务
 	//tx := db
 	if tx.Error != nil {
-		return errors.New("开启事务失败")
+		return fmt.Errorf("开启事务失败:%w", tx.Error)
 	}
 
 	err = fn()
 	if err != nil {
 		errRb := tx.Rollback().Error
 		if errRb != nil {
-			return errors.New("事务回滚失败")
+			return fmt.Errorf("事务回滚失败:%v，原错误:%w", errRb, err)
 		}
 		return err
 	}
 
 	//提交事务
-	err = tx.Commit().Error
-	if err != nil {
-		return errors.New(err.Error())
-	}
-	return nil
+	return tx.Commit().Error
 }
 
 // FilteredSQLInject 正则过滤sql注入的方法
@@ -0,0 +1,425 @@
+package specialdb
+
+/**
+  基于 sorted set 的延迟队列：任务持久化在 redis，进程重启不丢失，多副本共同消费
+  认领在 Lua 脚本中原子完成（等待队列 -> 处理中），同一任务同一时刻只会被一个副本处理；
+  处理中的任务带租约，处理期间定时续约，副本宕机、租约到期后任务回到等待队列重新投递，失败按退避时间重试，超过 MaxAttempts 转入死信
+  执行时间与租约均按 redis 服务端时钟计算，不受各副本时钟偏差影响
+    id, err := redisTemplate.Schedule(ctx, "order:auto-cancel", orderNo, time.Now().Add(30*time.Minute), specialdb.ScheduleID(orderNo))
+    ok, err := redisTemplate.Cancel(ctx, "order:auto-cancel", orderNo)
+    err := redisTemplate.RunDelayWorker(ctx, specialdb.DelayWorkerConfig{Queue: "order:auto-cancel"},
+        func(ctx context.Context, job specialdb.DelayJob) error { ... })
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// DelayJob 延迟任务
+type DelayJob struct {
+	Queue    string
+	ID       string
+	Payload  []byte
+	Attempts int64 // 投递次数，首次投递为 1
+}
+
+// DelayWorkerConfig 延迟队列消费配置
+type DelayWorkerConfig struct {
+	Queue        string        // 队列名称
+	Concurrency  int           // 并发处理数，默认1
+	BatchSize    int           // 每次认领数量，默认10
+	PollInterval time.Duration // 无到期任务时的轮询间隔，默认1秒
+	Lease        time.Duration // 处理租约，默认5分钟；处理期间每 Lease/3 续约一次，副本宕机超过租约后任务重新投递
+	MaxAttempts  int64         // 最大投递次数，超过后转入死信，默认5
+
+	// Backoff 第 attempts 次处理失败后的重试等待（可选），默认 1秒 * 2^(attempts-1)，最长10分钟
+	Backoff func(attempts int64) time.Duration
+	// OnDeadLetter 转入死信后回调（可选）
+	OnDeadLetter func(ctx context.Context, job DelayJob)
+}
+
+func (c *DelayWorkerConfig) setDefaults() {
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.Lease <= 0 {
+		c.Lease = 5 * time.Minute
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 5
+	}
+	if c.Backoff == nil {
+		c.Backoff = func(attempts int64) time.Duration {
+			if attempts > 10 {
+				return 10 * time.Minute
+			}
+			backoff := time.Second << (attempts - 1)
+			if backoff > 10*time.Minute {
+				backoff = 10 * time.Minute
+			}
+			return backoff
+		}
+	}
+}
+
+// delayKeys 队列的各个键，使用 hash tag 保证集群模式下位于同一槽位
+// 等待队列 zset（id -> 执行时间），处理中 zset（id -> 租约到期时间），任务内容 hash，投递次数 hash，死信 hash
+func delayKeys(queue string) (waiting, processing, data, attempts, dead string) {
+	prefix := "delay:{" + queue + "}"
+	return prefix, prefix + ":processing", prefix + ":data", prefix + ":attempts", prefix + ":dead"
+}
+
+// KEYS[1] 等待队列，KEYS[2] 处理中，KEYS[3] 任务内容，KEYS[4] 投递次数；ARGV[1] id，ARGV[2] 延迟（毫秒），ARGV[3] 任务内容
+const delayScheduleScript = luaNow + `
+	redis.call("hset", KEYS[3], ARGV[1], ARGV[3])
+	redis.call("hdel", KEYS[4], ARGV[1])
+	redis.call("zrem", KEYS[2], ARGV[1])
+	redis.call("zadd", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+	return 1
+`
+
+// KEYS 同上；ARGV[1] id。只能取消等待中的任务
+const delayCancelScript = `
+	if redis.call("zrem", KEYS[1], ARGV[1]) == 0 then
+		return 0
+	end
+	redis.call("hdel", KEYS[3], ARGV[1])
+	redis.call("hdel", KEYS[4], ARGV[1])
+	return 1
+`
+
+// KEYS 同上；ARGV[1] 租约（毫秒），ARGV[2] 认领数量
+// 先将租约到期的任务放回等待队列，再认领到期任务，返回 {租约到期时间, id, 内容, 投递次数, ...}
+const delayClaimScript = luaNow + `
+	local lease = now + tonumber(ARGV[1])
+	local expired = redis.call("zrangebyscore", KEYS[2], "-inf", now)
+	for _, id in ipairs(expired) do
+		redis.call("zrem", KEYS[2], id)
+		redis.call("zadd", KEYS[1], now, id)
+	end
+	local ids = redis.call("zrangebyscore", KEYS[1], "-inf", now, "LIMIT", 0, tonumber(ARGV[2]))
+	local result = {lease}
+	for _, id in ipairs(ids) do
+		redis.call("zrem", KEYS[1], id)
+		local data = redis.call("hget", KEYS[3], id)
+		if data then
+			redis.call("zadd", KEYS[2], lease, id)
+			local attempts = redis.call("hincrby", KEYS[4], id, 1)
+			table.insert(result, id)
+			table.insert(result, data)
+			table.insert(result, attempts)
+		end
+	end
+	return result
+`
+
+// KEYS 同上；ARGV[1] id，ARGV[2] 当前租约到期时间，ARGV[3] 租约（毫秒）。租约仍属于本次认领时续约，返回新的租约到期时间，否则返回 0
+const delayExtendScript = luaNow + `
+	if tonumber(redis.call("zscore", KEYS[2], ARGV[1])) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+	local lease = now + tonumber(ARGV[3])
+	redis.call("zadd", KEYS[2], lease, ARGV[1])
+	return lease
+`
+
+// KEYS 同上；ARGV[1] id，ARGV[2] 当前租约到期时间。租约仍属于本次认领时删除任务
+const delayAckScript = `
+	if tonumber(redis.call("zscore", KEYS[2], ARGV[1])) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+	redis.call("zrem", KEYS[2], ARGV[1])
+	redis.call("hdel", KEYS[3], ARGV[1])
+	redis.call("hdel", KEYS[4], ARGV[1])
+	return 1
+`
+
+// KEYS 同上；ARGV[1] id，ARGV[2] 当前租约到期时间，ARGV[3] 重试延迟（毫秒），ARGV[4] 为 1 时不计入投递次数
+const delayRetryScript = luaNow + `
+	if tonumber(redis.call("zscore", KEYS[2], ARGV[1])) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+	redis.call("zrem", KEYS[2], ARGV[1])
+	redis.call("zadd", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+	if ARGV[4] == "1" then
+		redis.call("hincrby", KEYS[4], ARGV[1], -1)
+	end
+	return 1
+`
+
+// KEYS 同上，KEYS[5] 死信；ARGV[1] id，ARGV[2] 当前租约到期时间
+const delayDeadScript = `
+	if tonumber(redis.call("zscore", KEYS[2], ARGV[1])) ~= tonumber(ARGV[2]) then
+		return 0
+	end
+	redis.call("zrem", KEYS[2], ARGV[1])
+	local data = redis.call("hget", KEYS[3], ARGV[1])
+	redis.call("hdel", KEYS[3], ARGV[1])
+	redis.call("hdel", KEYS[4], ARGV[1])
+	if data then
+		redis.call("hset", KEYS[5], ARGV[1], data)
+	end
+	return 1
+`
+
+// ScheduleOption Schedule 可选参数
+type ScheduleOption func(*scheduleOptions)
+
+type scheduleOptions struct {
+	id string
+}
+
+// ScheduleID 指定任务 ID（如订单号），便于按业务号取消；ID 已存在时覆盖内容与执行时间
+func ScheduleID(id string) ScheduleOption {
+	return func(o *scheduleOptions) { o.id = id }
+}
+
+// Schedule 添加延迟任务，在 runAt 之后执行，返回任务 ID；payload 为 []byte、string 时原样保存，其他类型按 Codec 编码
+// runAt 换算为距本机当前时间的延迟，按 redis 服务端时钟计算到期时间
+func (s *RedisTemplate) Schedule(ctx context.Context, queue string, payload interface{}, runAt time.Time, opts ...ScheduleOption) (string, error) {
+	o := scheduleOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.id == "" {
+		o.id = uuid.New().String()
+	}
+	waiting, processing, data, attempts, _ := delayKeys(queue)
+	value, err := s.encode(payload)
+	if err != nil {
+		return "", &RedisError{Op: "encode", Key: s.Key(waiting), Err: err}
+	}
+	keys := []string{waiting, processing, data, attempts}
+	if _, err = s.EvalLuaScript(delayScheduleScript, keys, []interface{}{o.id, time.Until(runAt).Milliseconds(), value}, ctx); err != nil {
+		return "", err
+	}
+	return o.id, nil
+}
+
+// Cancel 取消等待中的延迟任务，任务不存在或已开始处理时返回 false
+func (s *RedisTemplate) Cancel(ctx context.Context, queue, id string) (bool, error) {
+	waiting, processing, data, attempts, _ := delayKeys(queue)
+	return redis.Bool(s.EvalLuaScript(delayCancelScript, []string{waiting, processing, data, attempts}, []interface{}{id}, ctx))
+}
+
+// RunDelayWorker 处理到期的延迟任务，阻塞直到 ctx 结束；handler 返回错误时按 Backoff 重试
+func (s *RedisTemplate) RunDelayWorker(ctx context.Context, config DelayWorkerConfig, handler func(ctx context.Context, job DelayJob) error) error {
+	if config.Queue == "" {
+		return errors.New("redis: Queue 不能为空")
+	}
+	config.setDefaults()
+	w := &delayWorker{s: s, config: config, handler: handler}
+	waiting, processing, data, attempts, dead := delayKeys(config.Queue)
+	w.keys = []string{waiting, processing, data, attempts, dead}
+
+	jobs := make(chan claimedJob)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				w.process(ctx, job)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	backoff := time.Second
+	for ctx.Err() == nil {
+		claimed, err := w.claim(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Printf("------------RedisTemplate: DelayWorker %s 认领任务 err:%v，%v 后重试------------\n", config.Queue, err, backoff)
+			w.sleep(ctx, backoff)
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+		for i, job := range claimed {
+			select {
+			case jobs <- job:
+				continue
+			case <-ctx.Done():
+			}
+			// 已认领未处理的任务立即放回等待队列，不计入投递次数
+			for _, rest := range claimed[i:] {
+				w.requeue(context.WithoutCancel(ctx), rest, 0, true)
+			}
+			break
+		}
+		if len(claimed) < config.BatchSize {
+			w.sleep(ctx, config.PollInterval)
+		}
+	}
+	return ctx.Err()
+}
+
+// claimedJob 已认领的任务及其租约到期时间（redis 服务端时间，毫秒），同时作为认领凭证
+type claimedJob struct {
+	DelayJob
+	lease int64
+}
+
+type delayWorker struct {
+	s       *RedisTemplate
+	config  DelayWorkerConfig
+	keys    []string // 等待队列，处理中，任务内容，投递次数，死信
+	handler func(ctx context.Context, job DelayJob) error
+}
+
+func (w *delayWorker) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// claim 认领到期任务，投递次数超限的直接转入死信
+func (w *delayWorker) claim(ctx context.Context) ([]claimedJob, error) {
+	reply, err := redis.Values(w.s.EvalLuaScript(delayClaimScript, w.keys[:4],
+		[]interface{}{w.config.Lease.Milliseconds(), w.config.BatchSize}, ctx))
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) == 0 || (len(reply)-1)%3 != 0 {
+		return nil, errors.New("redis: 无法识别的延迟任务认领结果")
+	}
+	lease, err := redis.Int64(reply[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	reply = reply[1:]
+	jobs := make([]claimedJob, 0, len(reply)/3)
+	for i := 0; i < len(reply); i += 3 {
+		id, _ := redis.String(reply[i], nil)
+		payload, _ := redis.Bytes(reply[i+1], nil)
+		attempts, _ := redis.Int64(reply[i+2], nil)
+		job := claimedJob{DelayJob: DelayJob{Queue: w.config.Queue, ID: id, Payload: payload, Attempts: attempts}, lease: lease}
+		if attempts > w.config.MaxAttempts {
+			// 多次处理中宕机（租约到期）的任务
+			w.deadLetter(ctx, job)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// process 处理任务，处理期间续约；成功后删除，失败后按退避时间重试或转入死信
+func (w *delayWorker) process(ctx context.Context, job claimedJob) {
+	handlerCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.keepLease(handlerCtx, &job, cancel)
+	}()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("处理任务异常: %v", r)
+			}
+		}()
+		return w.handler(handlerCtx, job.DelayJob)
+	}()
+	cancel()
+	wg.Wait()
+	ctx = context.WithoutCancel(ctx)
+	if err == nil {
+		ok, err := redis.Bool(w.s.EvalLuaScript(delayAckScript, w.keys[:4], []interface{}{job.ID, job.lease}, ctx))
+		if err != nil {
+			fmt.Printf("------------RedisTemplate: DelayWorker %s 确认任务 %s err:%v------------\n", w.config.Queue, job.ID, err)
+		} else if !ok {
+			fmt.Printf("------------RedisTemplate: DelayWorker %s 任务 %s 租约已失效（已被其他副本认领或重新调度）------------\n", w.config.Queue, job.ID)
+		}
+		return
+	}
+	fmt.Printf("------------RedisTemplate: DelayWorker %s 任务 %s 第 %d 次处理失败:%v------------\n", w.config.Queue, job.ID, job.Attempts, err)
+	if job.Attempts >= w.config.MaxAttempts {
+		w.deadLetter(ctx, job)
+		return
+	}
+	w.requeue(ctx, job, w.config.Backoff(job.Attempts), false)
+}
+
+// keepLease 每 Lease/3 续约一次，直到 ctx 结束；租约已失效（被其他副本认领或重新调度）时调用 lost 取消处理
+func (w *delayWorker) keepLease(ctx context.Context, job *claimedJob, lost func()) {
+	ticker := time.NewTicker(w.config.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		lease, err := redis.Int64(w.s.EvalLuaScript(delayExtendScript, w.keys[:4],
+			[]interface{}{job.ID, job.lease, w.config.Lease.Milliseconds()}, context.WithoutCancel(ctx)))
+		if err != nil {
+			fmt.Printf("------------RedisTemplate: DelayWorker %s 任务 %s 续约 err:%v------------\n", w.config.Queue, job.ID, err)
+			continue
+		}
+		if lease == 0 {
+			fmt.Printf("------------RedisTemplate: DelayWorker %s 任务 %s 租约已失效，取消处理------------\n", w.config.Queue, job.ID)
+			lost()
+			return
+		}
+		job.lease = lease
+	}
+}
+
+// requeue 延迟 delay 后放回等待队列，refund 为 true 时不计入投递次数
+func (w *delayWorker) requeue(ctx context.Context, job claimedJob, delay time.Duration, refund bool) {
+	flag := "0"
+	if refund {
+		flag = "1"
+	}
+	args := []interface{}{job.ID, job.lease, delay.Milliseconds(), flag}
+	if _, err := w.s.EvalLuaScript(delayRetryScript, w.keys[:4], args, ctx); err != nil {
+		fmt.Printf("------------RedisTemplate: DelayWorker %s 重试任务 %s err:%v------------\n", w.config.Queue, job.ID, err)
+	}
+}
+
+// deadLetter 转入死信 hash（id -> 任务内容）
+func (w *delayWorker) deadLetter(ctx context.Context, job claimedJob) {
+	ok, err := redis.Bool(w.s.EvalLuaScript(delayDeadScript, w.keys, []interface{}{job.ID, job.lease}, ctx))
+	if err != nil {
+		fmt.Printf("------------RedisTemplate: DelayWorker %s 任务 %s 转入死信 err:%v------------\n", w.config.Queue, job.ID, err)
+		return
+	}
+	if ok && w.config.OnDeadLetter != nil {
+		w.config.OnDeadLetter(ctx, job.DelayJob)
+	}
+}
+
+// DelayQueueLen 等待中（含未到期）的任务数
+func (s *RedisTemplate) DelayQueueLen(ctx context.Context, queue string) (int64, error) {
+	waiting, _, _, _, _ := delayKeys(queue)
+	return s.ZCard(waiting, ctx)
+}
+
+// DeadDelayJobs 死信任务（id -> 任务内容）
+func (s *RedisTemplate) DeadDelayJobs(ctx context.Context, queue string) (map[string][]byte, error) {
+	_, _, _, _, dead := delayKeys(queue)
+	return s.HGetAll(dead, ctx)
+}
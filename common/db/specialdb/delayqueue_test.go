@@ -0,0 +1,150 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDelayQueue(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := s.Schedule(ctx, "order", "A001", time.Now().Add(-time.Second), ScheduleID("A001")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Schedule(ctx, "order", "A002", time.Now().Add(time.Hour), ScheduleID("A002")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Schedule(ctx, "order", "bad", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.Cancel(ctx, "order", "A002"); err != nil || !ok {
+		t.Fatalf("Cancel = %v, %v", ok, err)
+	}
+	if ok, _ := s.Cancel(ctx, "order", "A002"); ok {
+		t.Fatal("重复取消应返回 false")
+	}
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	dead := make(chan DelayJob, 1)
+	config := DelayWorkerConfig{
+		Queue:        "order",
+		Concurrency:  2,
+		PollInterval: 10 * time.Millisecond,
+		MaxAttempts:  2,
+		Backoff:      func(int64) time.Duration { return 10 * time.Millisecond },
+		OnDeadLetter: func(ctx context.Context, job DelayJob) { dead <- job },
+	}
+	go s.RunDelayWorker(ctx, config, func(ctx context.Context, job DelayJob) error {
+		mu.Lock()
+		defer mu.Unlock()
+		handled[string(job.Payload)]++
+		if string(job.Payload) == "bad" || handled[string(job.Payload)] == 1 {
+			return errors.New("处理失败")
+		}
+		return nil
+	})
+
+	select {
+	case job := <-dead:
+		if string(job.Payload) != "bad" || job.Attempts != 2 {
+			t.Fatalf("死信任务 = %+v", job)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("未转入死信")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if n, _ := s.HLen("delay:{order}:data", ctx); n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	if handled["A001"] != 2 || handled["A002"] != 0 || handled["bad"] != 2 {
+		t.Fatalf("handled = %v", handled)
+	}
+	mu.Unlock()
+	if jobs, err := s.DeadDelayJobs(ctx, "order"); err != nil || len(jobs) != 1 {
+		t.Fatalf("DeadDelayJobs = %v, %v", jobs, err)
+	}
+	if n, err := s.DelayQueueLen(ctx, "order"); err != nil || n != 0 {
+		t.Fatalf("DelayQueueLen = %d, %v", n, err)
+	}
+}
+
+func TestDelayQueueLease(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx := context.Background()
+	if _, err := s.Schedule(ctx, "sms", "hello", time.Now(), ScheduleID("1")); err != nil {
+		t.Fatal(err)
+	}
+	config := DelayWorkerConfig{Queue: "sms", Lease: 50 * time.Millisecond}
+	config.setDefaults()
+	w := &delayWorker{s: s, config: config}
+	waiting, processing, data, attempts, dead := delayKeys("sms")
+	w.keys = []string{waiting, processing, data, attempts, dead}
+
+	jobs, err := w.claim(ctx)
+	if err != nil || len(jobs) != 1 || jobs[0].Attempts != 1 {
+		t.Fatalf("claim = %+v, %v", jobs, err)
+	}
+	// 租约内其他副本无法认领
+	if again, _ := w.claim(ctx); len(again) != 0 {
+		t.Fatalf("重复认领: %+v", again)
+	}
+	// 租约到期（副本宕机）后重新投递，原认领者无法再确认
+	time.Sleep(100 * time.Millisecond)
+	again, err := w.claim(ctx)
+	if err != nil || len(again) != 1 || again[0].Attempts != 2 {
+		t.Fatalf("租约到期后 claim = %+v, %v", again, err)
+	}
+	if ok, _ := s.EvalLuaScript(delayAckScript, w.keys[:4], []interface{}{jobs[0].ID, jobs[0].lease}, ctx); ok != int64(0) {
+		t.Fatal("过期租约不应确认成功")
+	}
+	if ok, _ := s.EvalLuaScript(delayAckScript, w.keys[:4], []interface{}{again[0].ID, again[0].lease}, ctx); ok != int64(1) {
+		t.Fatal("确认失败")
+	}
+}
+
+func TestDelayQueueKeepLease(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := s.Schedule(ctx, "report", "r1", time.Now(), ScheduleID("r1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 处理时间超过租约：处理期间续约，不会被重新投递
+	var calls atomic.Int32
+	done := make(chan struct{})
+	config := DelayWorkerConfig{Queue: "report", Concurrency: 2, PollInterval: 10 * time.Millisecond, Lease: 60 * time.Millisecond}
+	go s.RunDelayWorker(ctx, config, func(ctx context.Context, job DelayJob) error {
+		if calls.Add(1) == 1 {
+			time.Sleep(300 * time.Millisecond)
+			close(done)
+		}
+		return nil
+	})
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("任务未处理")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if n, _ := s.HLen("delay:{report}:data", ctx); n == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n, _ := s.HLen("delay:{report}:data", ctx); n != 0 || calls.Load() != 1 {
+		t.Fatalf("处理次数 = %d, 剩余任务 = %d", calls.Load(), n)
+	}
+}
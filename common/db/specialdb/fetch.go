@@ -0,0 +1,271 @@
+package specialdb
+
+/**
+  缓存旁路读取：缓存未命中时调用 loader 加载并写入缓存，防止热点键过期后击穿到租户数据库
+    member, err := specialdb.Fetch(ctx, cache, "member:"+id, 10*time.Minute, func(ctx context.Context) (Member, error) {
+        return loadMember(ctx, id) // 数据不存在时返回 specialdb.ErrNotFound，结果同样会被短暂缓存
+    }, specialdb.FetchLock(3*time.Second), specialdb.FetchRefreshAhead(0.2))
+*/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// notFoundMarker 缓存“数据不存在”的占位值
+var notFoundMarker = []byte("\x00soelib:not-found")
+
+// fetchGroup 进程内合并同一键的并发加载
+var fetchGroup singleflight.Group
+
+type fetchOptions struct {
+	lockWait     time.Duration // 分布式锁等待时间，0 表示不使用分布式锁
+	lockExpire   time.Duration
+	jitter       float64
+	notFoundTTL  time.Duration
+	refreshAhead float64
+	loadTimeout  time.Duration
+}
+
+// FetchOption Fetch 参数
+type FetchOption func(*fetchOptions)
+
+// FetchLock 使用分布式锁（RedisTemplate.Lock）保证多个实例只有一个加载，其余实例最多等待 wait 后自行加载
+func FetchLock(wait time.Duration) FetchOption {
+	return func(o *fetchOptions) {
+		o.lockWait = wait
+	}
+}
+
+// FetchLockExpire 分布式锁过期时间，默认10秒，应大于加载耗时
+func FetchLockExpire(expire time.Duration) FetchOption {
+	return func(o *fetchOptions) {
+		o.lockExpire = expire
+	}
+}
+
+// FetchJitter 过期时间随机增加 [0, ttl*jitter)，避免同批写入的键同时过期，默认 0.1，0 表示不加随机
+func FetchJitter(jitter float64) FetchOption {
+	return func(o *fetchOptions) {
+		o.jitter = jitter
+	}
+}
+
+// FetchNotFoundTTL loader 返回 ErrNotFound 时的缓存时间，默认1分钟，0 表示不缓存
+func FetchNotFoundTTL(ttl time.Duration) FetchOption {
+	return func(o *fetchOptions) {
+		o.notFoundTTL = ttl
+	}
+}
+
+// FetchRefreshAhead 剩余过期时间低于 ttl*ratio 时返回旧值并在后台提前刷新，默认 0 不提前刷新
+func FetchRefreshAhead(ratio float64) FetchOption {
+	return func(o *fetchOptions) {
+		o.refreshAhead = ratio
+	}
+}
+
+// FetchLoadTimeout 加载超时时间，默认30秒（加载由并发请求共享，不随单个请求取消）
+func FetchLoadTimeout(timeout time.Duration) FetchOption {
+	return func(o *fetchOptions) {
+		o.loadTimeout = timeout
+	}
+}
+
+// Fetch 读取缓存，未命中时调用 loader 加载并写入缓存（ttl 必须大于 0）
+// loader 返回 ErrNotFound 时缓存“不存在”结果，在 FetchNotFoundTTL 内直接返回 ErrNotFound
+func Fetch[T any](ctx context.Context, s *RedisTemplate, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), opts ...FetchOption) (T, error) {
+	var zero T
+	if ttl <= 0 {
+		return zero, errors.New("Fetch: ttl 必须大于 0")
+	}
+	opt := &fetchOptions{lockExpire: 10 * time.Second, jitter: 0.1, notFoundTTL: time.Minute, loadTimeout: 30 * time.Second}
+	for _, o := range opts {
+		o(opt)
+	}
+	f := &fetcher[T]{s: s, key: key, fullKey: s.Key(key), ttl: ttl, loader: loader, opt: opt}
+
+	value, pttl, hit, err := f.read(ctx)
+	if err != nil || hit {
+		if hit && opt.refreshAhead > 0 && pttl > 0 && pttl < time.Duration(float64(ttl)*opt.refreshAhead) {
+			f.refresh(ctx)
+		}
+		return value, err
+	}
+
+	ch := fetchGroup.DoChan(f.fullKey, func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), opt.loadTimeout)
+		defer cancel()
+		return f.load(loadCtx, false)
+	})
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		value, ok := res.Val.(T)
+		if !ok {
+			return zero, fmt.Errorf("Fetch: 键 %s 的并发加载类型为 %T", key, res.Val)
+		}
+		return value, nil
+	}
+}
+
+type fetcher[T any] struct {
+	s       *RedisTemplate
+	key     string
+	fullKey string
+	ttl     time.Duration
+	loader  func(ctx context.Context) (T, error)
+	opt     *fetchOptions
+}
+
+// read 读取缓存，hit 为 true 时 err 可能为 ErrNotFound（命中“不存在”缓存）
+func (f *fetcher[T]) read(ctx context.Context) (value T, pttl time.Duration, hit bool, err error) {
+	conn := f.s.getConn()
+	defer safeClose(conn, "Fetch")
+	data, err := redis.Bytes(conn.Do("GET", f.s.buildArgs(ctx, []interface{}{f.fullKey})...))
+	if errors.Is(err, redis.ErrNil) {
+		return value, 0, false, nil
+	}
+	if err != nil {
+		return value, 0, false, &RedisError{Op: "GET", Key: f.fullKey, Err: err}
+	}
+	if bytes.Equal(data, notFoundMarker) {
+		return value, 0, true, ErrNotFound
+	}
+	if err = f.s.codec().Unmarshal(data, &value); err != nil {
+		// 无法解码（如数据格式变更）按未命中处理，重新加载覆盖
+		fmt.Printf("------------RedisTemplate: Fetch decode %s err:%v------------\n", f.fullKey, err)
+		return value, 0, false, nil
+	}
+	if f.opt.refreshAhead > 0 {
+		ms, err := redis.Int64(conn.Do("PTTL", f.s.buildArgs(ctx, []interface{}{f.fullKey})...))
+		if err == nil && ms > 0 {
+			pttl = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return value, pttl, true, nil
+}
+
+// load 加载并写入缓存；refresh 为 true 时为后台刷新，其他实例正在刷新时直接放弃
+func (f *fetcher[T]) load(ctx context.Context, refresh bool) (interface{}, error) {
+	if f.opt.lockWait > 0 {
+		lockKey := f.key + ":fetch-lock"
+		token := uuid.New().String()
+		expire := int(f.opt.lockExpire / time.Second)
+		if expire < 1 {
+			expire = 1
+		}
+		locked, err := f.s.Lock(lockKey, token, expire, ctx)
+		if err != nil {
+			fmt.Printf("------------RedisTemplate: Fetch Lock %s err:%v------------\n", f.fullKey, err)
+		}
+		switch {
+		case locked:
+			defer func() {
+				_ = f.s.Unlock(lockKey, token, context.WithoutCancel(ctx))
+			}()
+			// 等锁期间可能已被其他实例写入
+			if !refresh {
+				if value, _, hit, err := f.read(ctx); hit || err != nil {
+					return value, err
+				}
+			}
+		case refresh:
+			return nil, nil
+		case err == nil:
+			if value, hit, err := f.wait(ctx); hit || err != nil {
+				return value, err
+			}
+		}
+	}
+
+	value, err := f.loader(ctx)
+	if errors.Is(err, ErrNotFound) {
+		if f.opt.notFoundTTL > 0 {
+			f.write(ctx, notFoundMarker, f.opt.notFoundTTL)
+		}
+		return value, ErrNotFound
+	}
+	if err != nil {
+		return value, err
+	}
+	data, err := f.s.codec().Marshal(value)
+	if err != nil {
+		return value, &RedisError{Op: "encode", Key: f.fullKey, Err: err}
+	}
+	f.write(ctx, data, f.jitterTTL())
+	return value, nil
+}
+
+// wait 等待持有锁的实例写入缓存
+func (f *fetcher[T]) wait(ctx context.Context) (value T, hit bool, err error) {
+	deadline := time.NewTimer(f.opt.lockWait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return value, false, ctx.Err()
+		case <-deadline.C:
+			return value, false, nil
+		case <-ticker.C:
+			if value, _, hit, err = f.read(ctx); hit || err != nil {
+				return value, hit, err
+			}
+		}
+	}
+}
+
+// refresh 后台提前刷新，同一键同时只有一个刷新
+func (f *fetcher[T]) refresh(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("------------RedisTemplate: Fetch refresh %s panic:%v------------\n", f.fullKey, r)
+			}
+		}()
+		_, err, _ := fetchGroup.Do(f.fullKey+"\x00refresh", func() (interface{}, error) {
+			loadCtx, cancel := context.WithTimeout(ctx, f.opt.loadTimeout)
+			defer cancel()
+			return f.load(loadCtx, true)
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			fmt.Printf("------------RedisTemplate: Fetch refresh %s err:%v------------\n", f.fullKey, err)
+		}
+	}()
+}
+
+func (f *fetcher[T]) write(ctx context.Context, data []byte, ttl time.Duration) {
+	conn := f.s.getConn()
+	defer safeClose(conn, "Fetch")
+	args := append([]interface{}{f.fullKey, data}, expireArgs(ttl)...)
+	if _, err := conn.Do("SET", f.s.buildArgs(ctx, args)...); err != nil {
+		// 写缓存失败不影响返回加载结果
+		fmt.Printf("------------RedisTemplate: Fetch SET %s err:%v------------\n", f.fullKey, err)
+	}
+}
+
+// jitterTTL 过期时间加上随机抖动，并取整到毫秒
+func (f *fetcher[T]) jitterTTL() time.Duration {
+	ttl := f.ttl
+	if f.opt.jitter > 0 {
+		if n := int64(float64(ttl) * f.opt.jitter); n > 0 {
+			ttl += time.Duration(rand.Int64N(n))
+		}
+	}
+	return ttl.Truncate(time.Millisecond)
+}
@@ -0,0 +1,99 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (codecItem, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return codecItem{ID: 1, Name: "张三"}, nil
+	}
+	// 并发未命中只加载一次
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			item, err := Fetch(ctx, s, "member:1", time.Minute, loader, FetchLock(time.Second))
+			if err != nil || item.ID != 1 {
+				t.Errorf("Fetch = %v, %v", item, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("loader 调用 %d 次", calls.Load())
+	}
+	if ttl := mr.TTL("member:1"); ttl < time.Minute || ttl >= 66*time.Second {
+		t.Fatalf("过期时间 %v 不在抖动范围内", ttl)
+	}
+	if mr.Exists("member:1:fetch-lock") {
+		t.Fatal("分布式锁未释放")
+	}
+
+	// 不存在的结果被缓存
+	var missCalls atomic.Int32
+	missing := func(ctx context.Context) (codecItem, error) {
+		missCalls.Add(1)
+		return codecItem{}, ErrNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := Fetch(ctx, s, "member:2", time.Minute, missing, FetchNotFoundTTL(time.Second)); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Fetch err = %v", err)
+		}
+	}
+	if missCalls.Load() != 1 {
+		t.Fatalf("不存在的结果未缓存，loader 调用 %d 次", missCalls.Load())
+	}
+	mr.FastForward(2 * time.Second)
+	_, _ = Fetch(ctx, s, "member:2", time.Minute, missing)
+	if missCalls.Load() != 2 {
+		t.Fatal("不存在缓存过期后应重新加载")
+	}
+
+	// 加载失败不缓存
+	loadErr := errors.New("数据库异常")
+	if _, err := Fetch(ctx, s, "member:3", time.Minute, func(ctx context.Context) (codecItem, error) {
+		return codecItem{}, loadErr
+	}); !errors.Is(err, loadErr) || mr.Exists("member:3") {
+		t.Fatalf("加载失败 err = %v", err)
+	}
+}
+
+func TestFetchRefreshAhead(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	var version atomic.Int32
+	loader := func(ctx context.Context) (int32, error) {
+		return version.Add(1), nil
+	}
+	opts := []FetchOption{FetchRefreshAhead(0.5), FetchJitter(0)}
+	if v, _ := Fetch(ctx, s, "counter", 10*time.Second, loader, opts...); v != 1 {
+		t.Fatalf("首次加载 = %d", v)
+	}
+	mr.FastForward(6 * time.Second)
+	// 剩余时间低于一半：返回旧值并后台刷新
+	if v, _ := Fetch(ctx, s, "counter", 10*time.Second, loader, opts...); v != 1 {
+		t.Fatalf("提前刷新应返回旧值，得到 %d", v)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for version.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if v, _ := Fetch(ctx, s, "counter", 10*time.Second, loader, opts...); v != 2 {
+		t.Fatalf("后台刷新后 = %d", v)
+	}
+}
@@ -0,0 +1,107 @@
+package specialdb
+
+/**
+  哈希操作，值为 []byte、string 时原样写入，其他类型按 Codec 编码
+*/
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// HGet 读取哈希字段，不存在时返回 ErrNotFound
+func (s *RedisTemplate) HGet(key, field string, ctx context.Context) ([]byte, error) {
+	return doAs(s, redis.Bytes, "HGET", key, []interface{}{field}, ctx)
+}
+
+// HSet 写入哈希字段，返回新增的字段数
+func (s *RedisTemplate) HSet(key, field string, value interface{}, ctx context.Context) (int64, error) {
+	return s.HMSet(key, map[string]interface{}{field: value}, ctx)
+}
+
+// HMSet 批量写入哈希字段，返回新增的字段数
+func (s *RedisTemplate) HMSet(key string, values map[string]interface{}, ctx context.Context) (int64, error) {
+	args := make([]interface{}, 0, len(values)*2)
+	for field, value := range values {
+		data, err := s.encode(value)
+		if err != nil {
+			return 0, &RedisError{Op: "encode", Key: s.Key(key), Err: err}
+		}
+		args = append(args, field, data)
+	}
+	return doAs(s, redis.Int64, "HSET", key, args, ctx)
+}
+
+// HSetNX 字段不存在时写入，返回是否写入
+func (s *RedisTemplate) HSetNX(key, field string, value interface{}, ctx context.Context) (bool, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return false, &RedisError{Op: "encode", Key: s.Key(key), Err: err}
+	}
+	return doAs(s, redis.Bool, "HSETNX", key, []interface{}{field, data}, ctx)
+}
+
+// HMGet 批量读取哈希字段，返回存在的字段与值
+func (s *RedisTemplate) HMGet(key string, fields []string, ctx context.Context) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(fields))
+	if len(fields) == 0 {
+		return result, nil
+	}
+	args := make([]interface{}, len(fields))
+	for i, field := range fields {
+		args[i] = field
+	}
+	values, err := doAs(s, redis.ByteSlices, "HMGET", key, args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		if value != nil {
+			result[fields[i]] = value
+		}
+	}
+	return result, nil
+}
+
+// HGetAll 读取哈希全部字段与值，键不存在时返回空 map
+func (s *RedisTemplate) HGetAll(key string, ctx context.Context) (map[string][]byte, error) {
+	return doAs(s, byteMap, "HGETALL", key, nil, ctx)
+}
+
+// HIncrBy 哈希字段自增，返回自增后的值
+func (s *RedisTemplate) HIncrBy(key, field string, n int64, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "HINCRBY", key, []interface{}{field, n}, ctx)
+}
+
+// HDel 删除哈希字段，返回删除的字段数
+func (s *RedisTemplate) HDel(key string, fields []string, ctx context.Context) (int64, error) {
+	args := make([]interface{}, len(fields))
+	for i, field := range fields {
+		args[i] = field
+	}
+	return doAs(s, redis.Int64, "HDEL", key, args, ctx)
+}
+
+// HExists 哈希字段是否存在
+func (s *RedisTemplate) HExists(key, field string, ctx context.Context) (bool, error) {
+	return doAs(s, redis.Bool, "HEXISTS", key, []interface{}{field}, ctx)
+}
+
+// HLen 哈希字段数
+func (s *RedisTemplate) HLen(key string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "HLEN", key, nil, ctx)
+}
+
+// HGetAs 读取哈希字段并按 Codec 解码，不存在时返回 ErrNotFound
+func HGetAs[T any](ctx context.Context, s *RedisTemplate, key, field string) (T, error) {
+	var value T
+	data, err := s.HGet(key, field, ctx)
+	if err != nil {
+		return value, err
+	}
+	if err = s.codec().Unmarshal(data, &value); err != nil {
+		return value, &RedisError{Op: "decode", Key: s.Key(key), Err: err}
+	}
+	return value, nil
+}
@@ -0,0 +1,221 @@
+package specialdb
+
+/**
+  幂等处理：同一 key 的操作只执行一次，结果保存 ttl 时间，重复请求直接返回保存的结果
+  状态保存在 hash 中：processing（处理中，fn 执行期间定时续约，处理者宕机后租约到期自动释放）-> done（完成，保存结果）；fn 返回错误时删除状态，允许重试
+  可通过 OnceFingerprint 记录请求内容摘要，相同 key 不同内容的请求返回 ErrIdempotencyKeyMismatch
+    result, replayed, err := redisTemplate.Once(ctx, "pay:callback:"+tradeNo, 24*time.Hour, func(ctx context.Context) ([]byte, error) { ... })
+    order, replayed, err := specialdb.OnceAs(ctx, redisTemplate, "mq:"+msgID, time.Hour, handleOrder, specialdb.OnceWait(5*time.Second))
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// ErrIdempotencyInProgress 相同 key 的操作正在其他请求中处理
+var ErrIdempotencyInProgress = errors.New("redis: 相同请求正在处理中")
+
+// ErrIdempotencyKeyMismatch 相同 key 已用于内容不同的请求
+var ErrIdempotencyKeyMismatch = errors.New("redis: 幂等键已用于不同的请求")
+
+// ErrIdempotencyLeaseLost fn 执行成功，但处理中租约已失效（可能已被其他请求重复执行），结果未保存
+var ErrIdempotencyLeaseLost = errors.New("redis: 幂等处理租约已失效，可能已重复执行")
+
+// 幂等键前缀
+const idempotencyPrefix = "idempotency:"
+
+// KEYS[1] 状态 hash；ARGV[1] 处理者，ARGV[2] 处理中租约（毫秒），ARGV[3] 请求内容摘要（可为空）
+// 返回 {状态, 结果}，状态为 acquired 表示由本次请求处理，mismatch 表示请求内容与首次不同
+const idempotencyAcquireScript = `
+	local state = redis.call("hget", KEYS[1], "state")
+	if state then
+		local fingerprint = redis.call("hget", KEYS[1], "fingerprint")
+		if ARGV[3] ~= "" and fingerprint and fingerprint ~= ARGV[3] then
+			return {"mismatch", ""}
+		end
+		return {state, redis.call("hget", KEYS[1], "result") or ""}
+	end
+	redis.call("hset", KEYS[1], "state", "processing", "owner", ARGV[1])
+	if ARGV[3] ~= "" then
+		redis.call("hset", KEYS[1], "fingerprint", ARGV[3])
+	end
+	redis.call("pexpire", KEYS[1], ARGV[2])
+	return {"acquired", ""}
+`
+
+// KEYS[1] 状态 hash；ARGV[1] 处理者，ARGV[2] 处理中租约（毫秒）
+const idempotencyRenewScript = `
+	if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] or redis.call("hget", KEYS[1], "state") ~= "processing" then
+		return 0
+	end
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+`
+
+// KEYS[1] 状态 hash；ARGV[1] 处理者，ARGV[2] 结果，ARGV[3] 保存时间（毫秒）
+const idempotencyCompleteScript = `
+	if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] then
+		return 0
+	end
+	redis.call("hset", KEYS[1], "state", "done", "result", ARGV[2])
+	redis.call("pexpire", KEYS[1], ARGV[3])
+	return 1
+`
+
+// KEYS[1] 状态 hash；ARGV[1] 处理者
+const idempotencyReleaseScript = `
+	if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] then
+		return 0
+	end
+	return redis.call("del", KEYS[1])
+`
+
+// OnceOption Once 可选参数
+type OnceOption func(*onceOptions)
+
+type onceOptions struct {
+	wait        time.Duration
+	lockTTL     time.Duration
+	interval    time.Duration
+	fingerprint string
+}
+
+// OnceWait 相同 key 正在处理时最多等待 wait 获取其结果，默认不等待，直接返回 ErrIdempotencyInProgress
+func OnceWait(wait time.Duration) OnceOption {
+	return func(o *onceOptions) { o.wait = wait }
+}
+
+// OnceLockTTL 处理中状态的租约，fn 执行期间每 ttl/3 续约一次，处理者宕机时超过该时间后允许重新处理，默认1分钟
+func OnceLockTTL(ttl time.Duration) OnceOption {
+	return func(o *onceOptions) { o.lockTTL = ttl }
+}
+
+// OnceFingerprint 请求内容摘要（如请求体的 sha256），与首次请求不同时返回 ErrIdempotencyKeyMismatch
+func OnceFingerprint(fingerprint string) OnceOption {
+	return func(o *onceOptions) { o.fingerprint = fingerprint }
+}
+
+// Once 幂等执行 fn：首次调用执行 fn 并保存结果 ttl 时间，之后的调用直接返回保存的结果（replayed 为 true）
+// fn 返回错误时不保存结果，下次调用重新执行；fn 执行期间租约失效时取消 fn 的 ctx，执行成功也返回 ErrIdempotencyLeaseLost
+func (s *RedisTemplate) Once(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) ([]byte, error), opts ...OnceOption) (result []byte, replayed bool, err error) {
+	o := onceOptions{lockTTL: time.Minute, interval: 50 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	key = idempotencyPrefix + key
+	owner := uuid.New().String()
+	deadline := time.Now().Add(o.wait)
+	for {
+		reply, err := redis.ByteSlices(s.EvalLuaScript(idempotencyAcquireScript, []string{key}, []interface{}{owner, o.lockTTL.Milliseconds(), o.fingerprint}, ctx))
+		if err != nil {
+			return nil, false, err
+		}
+		if len(reply) != 2 {
+			return nil, false, &RedisError{Op: "idempotency", Key: s.Key(key), Err: errors.New("幂等脚本返回结果异常")}
+		}
+		switch string(reply[0]) {
+		case "done":
+			return reply[1], true, nil
+		case "acquired":
+			return s.runOnce(ctx, key, owner, ttl, o.lockTTL, fn)
+		case "mismatch":
+			return nil, false, ErrIdempotencyKeyMismatch
+		}
+		// 处理中：等待其他请求完成或失败释放
+		if !time.Now().Before(deadline) {
+			return nil, false, ErrIdempotencyInProgress
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-time.After(o.interval):
+		}
+	}
+}
+
+// runOnce 执行 fn 并保存结果，执行期间续约；fn 失败（含 panic）时释放处理中状态
+func (s *RedisTemplate) runOnce(ctx context.Context, key, owner string, ttl, lockTTL time.Duration, fn func(ctx context.Context) ([]byte, error)) (result []byte, replayed bool, err error) {
+	fnCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.renewOnce(fnCtx, key, owner, lockTTL, cancel)
+	}()
+	completed := false
+	defer func() {
+		cancel()
+		wg.Wait()
+		if !completed {
+			_, _ = s.EvalLuaScript(idempotencyReleaseScript, []string{key}, []interface{}{owner}, context.WithoutCancel(ctx))
+		}
+	}()
+	if result, err = fn(fnCtx); err != nil {
+		return nil, false, err
+	}
+	cancel()
+	wg.Wait()
+	// fn 已执行成功，保存结果失败时保留处理中状态直到租约到期，避免重复执行
+	completed = true
+	if result == nil {
+		result = []byte{}
+	}
+	ok, err := redis.Bool(s.EvalLuaScript(idempotencyCompleteScript, []string{key}, []interface{}{owner, result, ttl.Milliseconds()}, context.WithoutCancel(ctx)))
+	if err != nil {
+		return result, false, err
+	}
+	if !ok {
+		fmt.Printf("------------RedisTemplate: Once %s 处理完成时租约已失效，可能已被重复执行------------\n", s.Key(key))
+		return result, false, ErrIdempotencyLeaseLost
+	}
+	return result, false, nil
+}
+
+// renewOnce 每 lockTTL/3 续约处理中状态，直到 ctx 结束；租约已失效时调用 lost 取消 fn
+func (s *RedisTemplate) renewOnce(ctx context.Context, key, owner string, lockTTL time.Duration, lost func()) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		ok, err := redis.Bool(s.EvalLuaScript(idempotencyRenewScript, []string{key}, []interface{}{owner, lockTTL.Milliseconds()}, context.WithoutCancel(ctx)))
+		if err != nil {
+			fmt.Printf("------------RedisTemplate: Once %s 续约 err:%v------------\n", s.Key(key), err)
+			continue
+		}
+		if !ok {
+			fmt.Printf("------------RedisTemplate: Once %s 租约已失效，取消处理------------\n", s.Key(key))
+			lost()
+			return
+		}
+	}
+}
+
+// OnceAs 与 Once 相同，结果按 Codec 编码保存
+func OnceAs[T any](ctx context.Context, s *RedisTemplate, key string, ttl time.Duration, fn func(ctx context.Context) (T, error), opts ...OnceOption) (T, bool, error) {
+	var value T
+	data, replayed, err := s.Once(ctx, key, ttl, func(ctx context.Context) ([]byte, error) {
+		v, err := fn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+		return s.codec().Marshal(v)
+	}, opts...)
+	if err != nil || !replayed {
+		return value, replayed, err
+	}
+	if err = s.codec().Unmarshal(data, &value); err != nil {
+		return value, replayed, &RedisError{Op: "decode", Key: s.Key(idempotencyPrefix + key), Err: err}
+	}
+	return value, replayed, nil
+}
@@ -0,0 +1,176 @@
+package specialdb
+
+/**
+  gin 幂等中间件：请求带 Idempotency-Key 请求头时，相同 key 的重复请求直接返回首次的响应（响应头 Idempotent-Replayed: true）
+  首次请求处理中时重复请求返回 409；相同 key 请求体不同时返回 422；响应状态码 >= 500 时不保存，允许客户端重试；未带请求头的请求不受影响
+    router.POST("/pay/callback", specialdb.IdempotencyMiddleware(specialdb.IdempotencyConfig{Template: redisTemplate}), payCallback)
+*/
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soedev/soelib/net/app"
+)
+
+// IdempotencyConfig 幂等中间件配置
+type IdempotencyConfig struct {
+	Template    *RedisTemplate
+	ServiceName string        // 服务名称，同时作为幂等键前缀
+	Header      string        // 幂等键请求头，默认 Idempotency-Key
+	TTL         time.Duration // 响应保存时间，默认24小时
+	LockTTL     time.Duration // 处理中状态的租约，默认1分钟
+	Wait        time.Duration // 首次请求处理中时重复请求的最长等待时间，默认不等待直接返回 409
+	FailClosed  bool          // redis 异常时拒绝请求，默认放行
+}
+
+// idempotentResponse 保存的响应
+type idempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body"`
+}
+
+// errResponseNotSaved 响应状态码 >= 500，不保存
+var errResponseNotSaved = errors.New("响应不保存")
+
+// responseRecorder 记录响应内容
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware 幂等中间件，幂等键按 服务:租户:方法 路由:请求头 区分
+func IdempotencyMiddleware(config IdempotencyConfig) gin.HandlerFunc {
+	if config.Header == "" {
+		config.Header = "Idempotency-Key"
+	}
+	if config.TTL <= 0 {
+		config.TTL = 24 * time.Hour
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = time.Minute
+	}
+	return func(c *gin.Context) {
+		id := strings.TrimSpace(c.GetHeader(config.Header))
+		if id == "" {
+			c.Next()
+			return
+		}
+		fingerprint, err := requestFingerprint(c)
+		if err != nil {
+			appG := app.Gin{C: c, ServiceName: config.ServiceName, Error: err}
+			appG.ResponseError(http.StatusBadRequest, "读取请求内容失败")
+			c.Abort()
+			return
+		}
+		executed := false
+		data, replayed, err := config.Template.Once(c.Request.Context(), idempotencyKey(c, &config, id), config.TTL, func(ctx context.Context) ([]byte, error) {
+			executed = true
+			recorder := &responseRecorder{ResponseWriter: c.Writer}
+			c.Writer = recorder
+			c.Next()
+			c.Writer = recorder.ResponseWriter
+			if recorder.Status() >= http.StatusInternalServerError {
+				return nil, errResponseNotSaved
+			}
+			return json.Marshal(idempotentResponse{
+				Status:      recorder.Status(),
+				ContentType: recorder.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			})
+		}, OnceWait(config.Wait), OnceLockTTL(config.LockTTL), OnceFingerprint(fingerprint))
+		if executed {
+			// 请求已处理，保存失败只影响之后的重放
+			if err != nil && !errors.Is(err, errResponseNotSaved) {
+				fmt.Printf("------------RedisTemplate: Idempotency %s 保存响应 err:%v------------\n", id, err)
+			}
+			return
+		}
+		switch {
+		case errors.Is(err, ErrIdempotencyInProgress):
+			appG := app.Gin{C: c, ServiceName: config.ServiceName}
+			appG.ResponseError(http.StatusConflict, "相同请求正在处理中，请稍后重试")
+			c.Abort()
+		case errors.Is(err, ErrIdempotencyKeyMismatch):
+			appG := app.Gin{C: c, ServiceName: config.ServiceName}
+			appG.ResponseError(http.StatusUnprocessableEntity, "幂等键已用于不同的请求内容")
+			c.Abort()
+		case err != nil:
+			fmt.Printf("------------RedisTemplate: Idempotency %s err:%v------------\n", id, err)
+			if config.FailClosed {
+				appG := app.Gin{C: c, ServiceName: config.ServiceName, Error: err}
+				appG.ResponseError(http.StatusServiceUnavailable, "幂等服务不可用")
+				c.Abort()
+				return
+			}
+			c.Next()
+		case replayed:
+			var resp idempotentResponse
+			if err = json.Unmarshal(data, &resp); err != nil {
+				fmt.Printf("------------RedisTemplate: Idempotency %s 解析响应 err:%v------------\n", id, err)
+				c.Next()
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(resp.Status, resp.ContentType, resp.Body)
+			c.Abort()
+		}
+	}
+}
+
+// requestFingerprint 请求体的 sha256，读取后恢复请求体供后续处理
+func requestFingerprint(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return "", err
+		}
+		_ = c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// idempotencyKey 幂等键：服务:租户:方法 路由:请求头
+func idempotencyKey(c *gin.Context, config *IdempotencyConfig, id string) string {
+	parts := make([]string, 0, 4)
+	if config.ServiceName != "" {
+		parts = append(parts, config.ServiceName)
+	}
+	// 优先使用租户中间件识别的租户号，避免伪造请求头重放其他租户的响应
+	tenantID := c.GetString("tenantId")
+	if tenantID == "" {
+		tenantID = strings.TrimSpace(c.GetHeader("tenantId"))
+	}
+	if tenantID != "" {
+		parts = append(parts, tenantID)
+	}
+	route := c.FullPath()
+	if route == "" {
+		route = c.Request.URL.Path
+	}
+	return strings.Join(append(parts, c.Request.Method+" "+route, id), ":")
+}
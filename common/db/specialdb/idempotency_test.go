@@ -0,0 +1,194 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOnce(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	// fn 失败时不保存，允许重试
+	if _, _, err := s.Once(ctx, "pay:1", time.Hour, func(ctx context.Context) ([]byte, error) {
+		return nil, errors.New("下游超时")
+	}); err == nil {
+		t.Fatal("应返回 fn 的错误")
+	}
+
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (codecItem, error) {
+		calls.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return codecItem{ID: 1, Name: "已支付"}, nil
+	}
+	var wg sync.WaitGroup
+	var replays atomic.Int32
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, replayed, err := OnceAs(ctx, s, "pay:1", time.Hour, fn, OnceWait(time.Second))
+			if err != nil || v.Name != "已支付" {
+				t.Errorf("OnceAs = %+v, %v", v, err)
+			}
+			if replayed {
+				replays.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() != 1 || replays.Load() != 4 {
+		t.Fatalf("calls = %d, replays = %d", calls.Load(), replays.Load())
+	}
+
+	// 不等待时并发的重复请求返回 ErrIdempotencyInProgress
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go s.Once(ctx, "mq:1", time.Hour, func(ctx context.Context) ([]byte, error) {
+		close(started)
+		<-release
+		return []byte("ok"), nil
+	})
+	<-started
+	if _, _, err := s.Once(ctx, "mq:1", time.Hour, nil); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Fatalf("err = %v", err)
+	}
+	close(release)
+
+	// fn 执行时间超过租约时续约，其他请求不会重复执行
+	started = make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := s.Once(ctx, "mq:2", time.Hour, func(ctx context.Context) ([]byte, error) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			return []byte("ok"), nil
+		}, OnceLockTTL(60*time.Millisecond))
+		done <- err
+	}()
+	<-started
+	time.Sleep(120 * time.Millisecond)
+	if _, _, err := s.Once(ctx, "mq:2", time.Hour, nil); !errors.Is(err, ErrIdempotencyInProgress) {
+		t.Fatalf("续约后 err = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("续约后保存结果 err = %v", err)
+	}
+
+	// 租约失效（已被其他请求接手）时报告错误
+	result, _, err := s.Once(ctx, "mq:3", time.Hour, func(ctx context.Context) ([]byte, error) {
+		mr.Del(idempotencyPrefix + "mq:3")
+		return []byte("ok"), nil
+	})
+	if !errors.Is(err, ErrIdempotencyLeaseLost) || string(result) != "ok" {
+		t.Fatalf("租约失效 = %s, %v", result, err)
+	}
+
+	// 相同 key 不同请求内容
+	if _, _, err = s.Once(ctx, "pay:2", time.Hour, func(ctx context.Context) ([]byte, error) {
+		return []byte("ok"), nil
+	}, OnceFingerprint("a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, replayed, err := s.Once(ctx, "pay:2", time.Hour, nil, OnceFingerprint("a")); err != nil || !replayed {
+		t.Fatalf("相同内容 = %v, %v", replayed, err)
+	}
+	if _, _, err = s.Once(ctx, "pay:2", time.Hour, nil, OnceFingerprint("b")); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Fatalf("不同内容 err = %v", err)
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, _ := newTestTemplate(t)
+	router := gin.New()
+	router.Use(IdempotencyMiddleware(IdempotencyConfig{Template: s}))
+	var orders, failures int
+	router.POST("/order", func(c *gin.Context) {
+		orders++
+		c.JSON(http.StatusCreated, gin.H{"orderNo": orders})
+	})
+	router.POST("/fail", func(c *gin.Context) {
+		failures++
+		c.Status(http.StatusInternalServerError)
+	})
+
+	do := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"amount":100}`))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	first := do("/order", "k1")
+	if first.Code != http.StatusCreated || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("首次请求 %d %v", first.Code, first.Header())
+	}
+	w := do("/order", "k1")
+	if w.Code != http.StatusCreated || w.Body.String() != first.Body.String() || w.Header().Get("Idempotent-Replayed") != "true" ||
+		w.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Fatalf("重放响应 %d %s %v", w.Code, w.Body.String(), w.Header())
+	}
+	// 相同幂等键、不同请求体
+	req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"amount":200}`))
+	req.Header.Set("Idempotency-Key", "k1")
+	w = httptest.NewRecorder()
+	if router.ServeHTTP(w, req); w.Code != http.StatusUnprocessableEntity || orders != 1 {
+		t.Fatalf("不同请求体 %d, orders = %d", w.Code, orders)
+	}
+	if do("/order", "k2"); orders != 2 {
+		t.Fatalf("不同幂等键应分别处理, orders = %d", orders)
+	}
+	if do("/order", ""); orders != 3 {
+		t.Fatal("未带幂等键的请求不受影响")
+	}
+	do("/fail", "k1")
+	do("/fail", "k1")
+	if failures != 2 {
+		t.Fatalf("5xx 响应不应保存, failures = %d", failures)
+	}
+}
+
+func TestIdempotencyKeyPrefersContextTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, _ := newTestTemplate(t)
+	router := gin.New()
+	// 模拟租户中间件：按登录信息识别租户
+	router.Use(func(c *gin.Context) { c.Set("tenantId", c.GetHeader("X-Login-Tenant")) })
+	router.Use(IdempotencyMiddleware(IdempotencyConfig{Template: s}))
+	var orders int
+	router.POST("/order", func(c *gin.Context) {
+		orders++
+		c.JSON(http.StatusCreated, gin.H{"orderNo": orders})
+	})
+
+	do := func(loginTenant, headerTenant string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/order", strings.NewReader(`{"amount":100}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("X-Login-Tenant", loginTenant)
+		req.Header.Set("tenantId", headerTenant)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	do("1001", "1001")
+	// 租户 2001 伪造请求头 tenantId=1001，不应拿到 1001 的缓存响应
+	if w := do("2001", "1001"); w.Header().Get("Idempotent-Replayed") != "" || orders != 2 {
+		t.Fatalf("伪造租户请求头不应重放, replayed=%q orders=%d", w.Header().Get("Idempotent-Replayed"), orders)
+	}
+	if w := do("1001", "2001"); w.Header().Get("Idempotent-Replayed") != "true" || orders != 2 {
+		t.Fatalf("同一租户应重放, orders=%d", orders)
+	}
+}
@@ -0,0 +1,114 @@
+package specialdb
+
+/**
+  列表操作，值为 []byte、string 时原样写入，其他类型按 Codec 编码
+*/
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// encodeValues 编码多个值
+func (s *RedisTemplate) encodeValues(key string, values []interface{}) ([]interface{}, error) {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		data, err := s.encode(value)
+		if err != nil {
+			return nil, &RedisError{Op: "encode", Key: s.Key(key), Err: err}
+		}
+		args[i] = data
+	}
+	return args, nil
+}
+
+// LPush 从头部插入，返回插入后的列表长度
+func (s *RedisTemplate) LPush(key string, values []interface{}, ctx context.Context) (int64, error) {
+	args, err := s.encodeValues(key, values)
+	if err != nil {
+		return 0, err
+	}
+	return doAs(s, redis.Int64, "LPUSH", key, args, ctx)
+}
+
+// RPush 从尾部插入，返回插入后的列表长度
+func (s *RedisTemplate) RPush(key string, values []interface{}, ctx context.Context) (int64, error) {
+	args, err := s.encodeValues(key, values)
+	if err != nil {
+		return 0, err
+	}
+	return doAs(s, redis.Int64, "RPUSH", key, args, ctx)
+}
+
+// LPop 从头部弹出，列表为空时返回 ErrNotFound
+func (s *RedisTemplate) LPop(key string, ctx context.Context) ([]byte, error) {
+	return doAs(s, redis.Bytes, "LPOP", key, nil, ctx)
+}
+
+// RPop 从尾部弹出，列表为空时返回 ErrNotFound
+func (s *RedisTemplate) RPop(key string, ctx context.Context) ([]byte, error) {
+	return doAs(s, redis.Bytes, "RPOP", key, nil, ctx)
+}
+
+// BLPop 阻塞从头部弹出，返回弹出的键（不含命名空间）与值；超时返回 ErrNotFound（timeout 为 0 表示一直等待）
+// 集群模式下多个键必须位于同一槽位
+func (s *RedisTemplate) BLPop(keys []string, timeout time.Duration, ctx context.Context) (string, []byte, error) {
+	return s.blockingPop("BLPOP", keys, timeout, ctx)
+}
+
+// BRPop 阻塞从尾部弹出，返回弹出的键（不含命名空间）与值；超时返回 ErrNotFound（timeout 为 0 表示一直等待）
+func (s *RedisTemplate) BRPop(keys []string, timeout time.Duration, ctx context.Context) (string, []byte, error) {
+	return s.blockingPop("BRPOP", keys, timeout, ctx)
+}
+
+func (s *RedisTemplate) blockingPop(cmd string, keys []string, timeout time.Duration, ctx context.Context) (string, []byte, error) {
+	fullKeys := make([]string, len(keys))
+	args := make([]interface{}, 0, len(keys)+1)
+	for i, key := range keys {
+		fullKeys[i] = s.Key(key)
+		args = append(args, fullKeys[i])
+	}
+	// 秒为单位，支持小数（redis 6.0+）
+	args = append(args, timeout.Seconds())
+	conn := s.getConn(fullKeys...)
+	defer safeClose(conn, cmd)
+	values, err := redis.ByteSlices(conn.Do(cmd, s.buildArgs(ctx, args)...))
+	if err != nil {
+		return "", nil, wrapErr(cmd, s.Key(keys[0]), err)
+	}
+	key := string(values[0])
+	for i, fullKey := range fullKeys {
+		if fullKey == key {
+			key = keys[i]
+			break
+		}
+	}
+	return key, values[1], nil
+}
+
+// LRange 读取列表区间 [start, stop]，负数表示从尾部计数
+func (s *RedisTemplate) LRange(key string, start, stop int64, ctx context.Context) ([][]byte, error) {
+	return doAs(s, redis.ByteSlices, "LRANGE", key, []interface{}{start, stop}, ctx)
+}
+
+// LLen 列表长度
+func (s *RedisTemplate) LLen(key string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "LLEN", key, nil, ctx)
+}
+
+// LTrim 只保留区间 [start, stop] 内的元素
+func (s *RedisTemplate) LTrim(key string, start, stop int64, ctx context.Context) error {
+	_, err := doAs(s, redis.String, "LTRIM", key, []interface{}{start, stop}, ctx)
+	return err
+}
+
+// LRem 删除 count 个等于 value 的元素（count>0 从头部，<0 从尾部，0 全部），返回删除数
+func (s *RedisTemplate) LRem(key string, count int64, value interface{}, ctx context.Context) (int64, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return 0, &RedisError{Op: "encode", Key: s.Key(key), Err: err}
+	}
+	return doAs(s, redis.Int64, "LREM", key, []interface{}{count, data}, ctx)
+}
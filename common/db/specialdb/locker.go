@@ -0,0 +1,303 @@
+package specialdb
+
+/**
+  分布式锁：阻塞获取（重试退避）、看门狗自动续期、同一持有者可重入、fencing token 防止过期持有者误写
+    locker := redisTemplate.NewLocker("job:sync-member", specialdb.LockTTL(30*time.Second))
+    token, err := locker.Acquire(ctx)
+    if err != nil { ... }
+    defer locker.Release(ctx)
+    // 写入外部存储时携带 token，存储端拒绝小于已见过的 token 的写入
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrLockNotObtained 在超时或取消前未获取到锁
+	ErrLockNotObtained = errors.New("redis: 未获取到锁")
+	// ErrLockNotHeld 锁不属于当前持有者（未获取或已过期被他人获取）
+	ErrLockNotHeld = errors.New("redis: 未持有该锁")
+)
+
+// 锁以 hash 保存：owner 持有者、count 重入次数、token fencing token
+// KEYS[1] 锁，KEYS[2] fencing 计数器；ARGV[1] 持有者，ARGV[2] 过期时间（毫秒）
+const (
+	lockAcquireScript = `
+		local owner = redis.call("hget", KEYS[1], "owner")
+		if not owner then
+			local token = redis.call("incr", KEYS[2])
+			redis.call("hset", KEYS[1], "owner", ARGV[1], "count", 1, "token", token)
+			redis.call("pexpire", KEYS[1], ARGV[2])
+			return token
+		end
+		if owner == ARGV[1] then
+			redis.call("hincrby", KEYS[1], "count", 1)
+			redis.call("pexpire", KEYS[1], ARGV[2])
+			return tonumber(redis.call("hget", KEYS[1], "token"))
+		end
+		return 0
+	`
+	lockReleaseScript = `
+		if redis.call("hget", KEYS[1], "owner") ~= ARGV[1] then
+			return -1
+		end
+		local count = redis.call("hincrby", KEYS[1], "count", -1)
+		if count > 0 then
+			redis.call("pexpire", KEYS[1], ARGV[2])
+			return count
+		end
+		redis.call("del", KEYS[1])
+		return 0
+	`
+	lockRenewScript = `
+		if redis.call("hget", KEYS[1], "owner") == ARGV[1] then
+			return redis.call("pexpire", KEYS[1], ARGV[2])
+		end
+		return 0
+	`
+)
+
+type lockerOptions struct {
+	ttl        time.Duration
+	owner      string
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	watchdog   bool
+}
+
+// LockerOption Locker 参数
+type LockerOption func(*lockerOptions)
+
+// LockTTL 锁过期时间，默认30秒；启用看门狗时每 ttl/3 续期一次
+func LockTTL(ttl time.Duration) LockerOption {
+	return func(o *lockerOptions) {
+		o.ttl = ttl
+	}
+}
+
+// LockOwner 持有者标识，默认随机生成；相同持有者可重入（如同一任务在多个 Locker 间共享）
+func LockOwner(owner string) LockerOption {
+	return func(o *lockerOptions) {
+		o.owner = owner
+	}
+}
+
+// LockBackoff Acquire 重试间隔，从 min 开始翻倍至 max，默认 50ms ~ 1s
+func LockBackoff(min, max time.Duration) LockerOption {
+	return func(o *lockerOptions) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// LockWatchdog 是否启用看门狗自动续期，默认启用
+func LockWatchdog(enable bool) LockerOption {
+	return func(o *lockerOptions) {
+		o.watchdog = enable
+	}
+}
+
+// Locker 分布式锁，同一 Locker 可重入，Acquire 与 Release 需成对调用
+type Locker struct {
+	s   *RedisTemplate
+	key string
+	opt lockerOptions
+
+	mu    sync.Mutex
+	held  int   // 本地重入次数
+	token int64 // 当前 fencing token
+	stop  context.CancelFunc
+	lost  chan struct{}
+}
+
+// NewLocker 创建分布式锁（键使用 RedisTemplate 的命名空间）
+func (s *RedisTemplate) NewLocker(key string, opts ...LockerOption) *Locker {
+	opt := lockerOptions{ttl: 30 * time.Second, minBackoff: 50 * time.Millisecond, maxBackoff: time.Second, watchdog: true}
+	for _, o := range opts {
+		o(&opt)
+	}
+	if opt.owner == "" {
+		opt.owner = uuid.New().String()
+	}
+	if opt.ttl < time.Millisecond {
+		opt.ttl = time.Millisecond
+	}
+	if opt.minBackoff <= 0 {
+		opt.minBackoff = 50 * time.Millisecond
+	}
+	if opt.maxBackoff < opt.minBackoff {
+		opt.maxBackoff = opt.minBackoff
+	}
+	return &Locker{s: s, key: key, opt: opt}
+}
+
+// keys 锁与 fencing 计数器使用相同的 hash tag，集群模式下位于同一槽位
+func (l *Locker) keys() []string {
+	tagged := "{" + l.key + "}"
+	return []string{tagged, tagged + ":fencing"}
+}
+
+// Owner 持有者标识
+func (l *Locker) Owner() string {
+	return l.opt.owner
+}
+
+// Token 当前持有锁的 fencing token，未持有时返回 0；每次重新获取（非重入）单调递增
+func (l *Locker) Token() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.token
+}
+
+// Lost 看门狗续期失败（锁已过期或被他人获取）时关闭，关闭后保持到重新获取成功或调用 Release；正常释放时不关闭，未持有锁时返回 nil
+func (l *Locker) Lost() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lost
+}
+
+// TryAcquire 尝试获取锁一次，成功时返回 fencing token
+func (l *Locker) TryAcquire(ctx context.Context) (token int64, ok bool, err error) {
+	reply, err := l.s.EvalLuaScript(lockAcquireScript, l.keys(), []interface{}{l.opt.owner, l.opt.ttl.Milliseconds()}, ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	token, err = redis.Int64(reply, nil)
+	if err != nil {
+		return 0, false, &RedisError{Op: "lock", Key: l.s.Key(l.keys()[0]), Err: err}
+	}
+	if token == 0 {
+		return 0, false, nil
+	}
+	l.mu.Lock()
+	l.held++
+	l.token = token
+	if l.held == 1 && l.opt.watchdog {
+		l.startWatchdog(ctx)
+	}
+	l.mu.Unlock()
+	return token, true, nil
+}
+
+// Acquire 获取锁，未获取到时按退避间隔重试，直到 ctx 超时或取消
+func (l *Locker) Acquire(ctx context.Context) (int64, error) {
+	backoff := l.opt.minBackoff
+	for {
+		token, ok, err := l.TryAcquire(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return token, nil
+		}
+		// 随机化等待时间，避免多个等待者同时重试
+		wait := backoff/2 + time.Duration(rand.Int64N(int64(backoff/2)+1))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, fmt.Errorf("%w: %w", ErrLockNotObtained, ctx.Err())
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > l.opt.maxBackoff {
+			backoff = l.opt.maxBackoff
+		}
+	}
+}
+
+// Release 释放一次锁，重入次数归零时删除锁并停止看门狗；锁已不属于当前持有者时返回 ErrLockNotHeld
+func (l *Locker) Release(ctx context.Context) error {
+	reply, err := l.s.EvalLuaScript(lockReleaseScript, l.keys()[:1], []interface{}{l.opt.owner, l.opt.ttl.Milliseconds()}, ctx)
+	if err != nil {
+		return err
+	}
+	count, _ := redis.Int64(reply, nil)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if count < 0 {
+		l.reset()
+		return ErrLockNotHeld
+	}
+	if l.held > 0 {
+		l.held--
+	}
+	if count == 0 || l.held == 0 {
+		l.reset()
+	}
+	return nil
+}
+
+// reset 清除本地持有状态并停止看门狗（需持有 mu）
+func (l *Locker) reset() {
+	l.held = 0
+	l.token = 0
+	if l.stop != nil {
+		l.stop()
+		l.stop = nil
+	}
+	l.lost = nil
+}
+
+// startWatchdog 定时续期直到释放或续期失败（需持有 mu）
+func (l *Locker) startWatchdog(ctx context.Context) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	lost := make(chan struct{})
+	l.stop, l.lost = cancel, lost
+	interval := l.opt.ttl / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("------------RedisTemplate: Locker watchdog %s panic:%v------------\n", l.key, r)
+			}
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failures := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			reply, err := l.s.EvalLuaScript(lockRenewScript, l.keys()[:1], []interface{}{l.opt.owner, l.opt.ttl.Milliseconds()}, ctx)
+			if ctx.Err() != nil {
+				// 续期期间已释放
+				return
+			}
+			if err != nil {
+				// 网络异常时在过期前继续重试
+				if failures++; time.Duration(failures)*interval < l.opt.ttl {
+					continue
+				}
+			} else if renewed, _ := redis.Int(reply, nil); renewed == 1 {
+				failures = 0
+				continue
+			}
+			fmt.Printf("------------RedisTemplate: Locker %s 续期失败，锁已丢失:%v------------\n", l.key, err)
+			// 保留已关闭的 lost，之后调用 Lost 的等待者立即返回，直到重新获取或 Release
+			l.mu.Lock()
+			if l.lost == lost {
+				l.held = 0
+				l.token = 0
+				l.stop = nil
+			}
+			l.mu.Unlock()
+			close(lost)
+			cancel()
+			return
+		}
+	}()
+}
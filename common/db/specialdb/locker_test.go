@@ -0,0 +1,111 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocker(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	a := s.NewLocker("job", LockTTL(time.Second), LockWatchdog(false))
+	b := s.NewLocker("job", LockTTL(time.Second), LockWatchdog(false))
+	token1, err := a.Acquire(ctx)
+	if err != nil || token1 != 1 {
+		t.Fatalf("Acquire = %d, %v", token1, err)
+	}
+	// 同一持有者可重入，token 不变
+	if token, ok, err := a.TryAcquire(ctx); !ok || err != nil || token != token1 {
+		t.Fatalf("重入 TryAcquire = %d, %v, %v", token, ok, err)
+	}
+	if _, ok, _ := b.TryAcquire(ctx); ok {
+		t.Fatal("其他持有者不应获取到锁")
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	_, err = b.Acquire(waitCtx)
+	cancel()
+	if !errors.Is(err, ErrLockNotObtained) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire 超时 err = %v", err)
+	}
+	if err = b.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("未持有时 Release err = %v", err)
+	}
+
+	// 释放两次后其他持有者才能获取，fencing token 递增
+	_ = a.Release(ctx)
+	if !mr.Exists("{job}") {
+		t.Fatal("重入未全部释放时不应删除锁")
+	}
+	_ = a.Release(ctx)
+	token2, err := b.Acquire(ctx)
+	if err != nil || token2 <= token1 {
+		t.Fatalf("重新获取 token = %d, %v", token2, err)
+	}
+
+	// 过期后被他人获取
+	mr.FastForward(2 * time.Second)
+	if _, err = a.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Release(ctx); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("过期后 Release err = %v", err)
+	}
+	if a.Token() <= token2 {
+		t.Fatalf("token 未递增: %d", a.Token())
+	}
+}
+
+func TestLockerWatchdog(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	l := s.NewLocker("job", LockTTL(300*time.Millisecond))
+	if _, err := l.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// miniredis 不会自动过期，按续期间隔推进时间
+	for i := 0; i < 5; i++ {
+		time.Sleep(150 * time.Millisecond)
+		mr.FastForward(150 * time.Millisecond)
+		if !mr.Exists("{job}") {
+			t.Fatal("看门狗未续期")
+		}
+	}
+	lost := l.Lost()
+	mr.Del("{job}")
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("锁丢失后未通知")
+	}
+	if l.Token() != 0 {
+		t.Fatal("锁丢失后应清除持有状态")
+	}
+	// 丢失之后才调用 Lost 同样立即返回
+	select {
+	case <-l.Lost():
+	default:
+		t.Fatal("锁丢失后 Lost 应保持关闭")
+	}
+
+	if _, err := l.Acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-l.Lost():
+		t.Fatal("重新获取后 Lost 不应关闭")
+	default:
+	}
+	if err := l.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if l.Lost() != nil {
+		t.Fatal("释放后 Lost 应为 nil")
+	}
+	if mr.Exists("{job}") {
+		t.Fatal("释放后锁仍存在")
+	}
+}
@@ -1,20 +1,33 @@
 package specialdb
 
 import (
+	"context"
 	"fmt"
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.mongodb.org/mongo-driver/v2/mongo/readpref"
 	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/v2/mongo/otelmongo"
 )
 
+// 租户数据隔离方式
+const (
+	MongoTenantShared   = ""         // 所有租户共用 DbName
+	MongoTenantDatabase = "database" // 每个租户独立数据库：DbName_租户号
+	MongoTenantPrefix   = "prefix"   // 共用 DbName，集合名加租户前缀：租户号_集合名
+)
+
 type MongoConfig struct {
 	DSN         string
 	PoolLimit   int
 	DbName      string
 	EnableTrace bool
+	TenantMode  string        // 租户数据隔离方式，默认共用数据库
+	PingTimeout time.Duration // 启动连接检查超时时间，默认10秒
 }
 
-// ConnMongo 初始化数据库
+// ConnMongo 初始化数据库，连接后 Ping 主节点确认可用
 func ConnMongo(config MongoConfig) (*mongo.Client, error) {
 	opts := options.Client()
 	mps := uint64(config.PoolLimit)
@@ -27,5 +40,20 @@ func ConnMongo(config MongoConfig) (*mongo.Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("数据库连接失败:%s", err.Error())
 	}
+	timeout := config.PingTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if err = MongoHealthCheck(context.Background(), client, timeout); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("数据库连接失败:%s", err.Error())
+	}
 	return client, nil
 }
+
+// MongoHealthCheck 在 timeout 内 Ping 主节点，用于启动检查与健康检查接口
+func MongoHealthCheck(ctx context.Context, client *mongo.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return client.Ping(ctx, readpref.Primary())
+}
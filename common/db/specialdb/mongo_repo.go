@@ -0,0 +1,254 @@
+package specialdb
+
+/**
+  mongo 通用仓储：按租户选择数据库或集合（见 MongoConfig.TenantMode），首次访问租户集合时自动创建声明的索引
+  默认软删除：Delete 设置 deletedAt，查询自动排除已删除的文档
+    store := specialdb.NewMongoStore(client, config.MongoConfig, tenantdb.TenantIDFromContext)
+    memberRepo := specialdb.NewRepository[Member](store, "member",
+        specialdb.RepoIndexes(mongo.IndexModel{Keys: bson.D{{Key: "mobile", Value: 1}}}))
+    members, total, err := memberRepo.Find(ctx, bson.M{"shopCode": shopCode}, 1, 20, bson.D{{Key: "createdAt", Value: -1}})
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ErrMongoTenantRequired 按租户隔离时未能从 ctx 识别租户
+var ErrMongoTenantRequired = errors.New("mongo: 未识别租户")
+
+// MongoStore 按租户选择数据库与集合
+type MongoStore struct {
+	Client     *mongo.Client
+	DbName     string
+	TenantMode string
+	TenantFunc func(ctx context.Context) string // 从 ctx 取得租户号，如 tenantdb.TenantIDFromContext
+}
+
+// NewMongoStore 创建 MongoStore，tenantFunc 在共用数据库时可为 nil
+func NewMongoStore(client *mongo.Client, config MongoConfig, tenantFunc func(ctx context.Context) string) *MongoStore {
+	return &MongoStore{Client: client, DbName: config.DbName, TenantMode: config.TenantMode, TenantFunc: tenantFunc}
+}
+
+// Collection 取得当前租户的集合
+func (m *MongoStore) Collection(ctx context.Context, name string) (*mongo.Collection, error) {
+	dbName, collName, err := m.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return m.Client.Database(dbName).Collection(collName), nil
+}
+
+// resolve 按租户隔离方式计算数据库名与集合名
+func (m *MongoStore) resolve(ctx context.Context, name string) (dbName, collName string, err error) {
+	if m.TenantMode == MongoTenantShared {
+		return m.DbName, name, nil
+	}
+	var tenantID string
+	if m.TenantFunc != nil {
+		tenantID = m.TenantFunc(ctx)
+	}
+	if tenantID == "" {
+		return "", "", ErrMongoTenantRequired
+	}
+	switch m.TenantMode {
+	case MongoTenantDatabase:
+		return m.DbName + "_" + tenantID, name, nil
+	case MongoTenantPrefix:
+		return m.DbName, tenantID + "_" + name, nil
+	default:
+		return "", "", fmt.Errorf("mongo: 不支持的租户隔离方式 %s", m.TenantMode)
+	}
+}
+
+// RepositoryOption Repository 可选参数
+type RepositoryOption func(*repositoryOptions)
+
+type repositoryOptions struct {
+	indexes     []mongo.IndexModel
+	deleteField string
+}
+
+// RepoIndexes 声明索引，首次访问每个租户的集合时创建
+func RepoIndexes(models ...mongo.IndexModel) RepositoryOption {
+	return func(o *repositoryOptions) { o.indexes = append(o.indexes, models...) }
+}
+
+// RepoSoftDelete 软删除时间字段，默认 deletedAt，为空时 Delete 直接删除文档
+func RepoSoftDelete(field string) RepositoryOption {
+	return func(o *repositoryOptions) { o.deleteField = field }
+}
+
+// Repository 集合的通用增删改查
+type Repository[T any] struct {
+	store   *MongoStore
+	name    string
+	options repositoryOptions
+	indexed sync.Map // 已创建索引的 数据库.集合
+}
+
+// NewRepository 创建集合仓储
+func NewRepository[T any](store *MongoStore, collection string, opts ...RepositoryOption) *Repository[T] {
+	o := repositoryOptions{deleteField: "deletedAt"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Repository[T]{store: store, name: collection, options: o}
+}
+
+// Collection 取得当前租户的集合（用于聚合等自定义操作），首次访问时创建声明的索引
+func (r *Repository[T]) Collection(ctx context.Context) (*mongo.Collection, error) {
+	coll, err := r.store.Collection(ctx, r.name)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.options.indexes) > 0 {
+		name := coll.Database().Name() + "." + coll.Name()
+		if _, ok := r.indexed.Load(name); !ok {
+			if _, err = coll.Indexes().CreateMany(ctx, r.options.indexes); err != nil {
+				return nil, fmt.Errorf("mongo: 创建索引 %s 失败:%w", name, err)
+			}
+			r.indexed.Store(name, struct{}{})
+		}
+	}
+	return coll, nil
+}
+
+// filter 排除已软删除的文档
+func (r *Repository[T]) filter(filter interface{}) interface{} {
+	if filter == nil {
+		filter = bson.D{}
+	}
+	if r.options.deleteField == "" {
+		return filter
+	}
+	return bson.D{{Key: "$and", Value: bson.A{filter, bson.D{{Key: r.options.deleteField, Value: nil}}}}}
+}
+
+// FindOne 查询一条，不存在时返回 mongo.ErrNoDocuments
+func (r *Repository[T]) FindOne(ctx context.Context, filter interface{}) (T, error) {
+	var doc T
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return doc, err
+	}
+	err = coll.FindOne(ctx, r.filter(filter)).Decode(&doc)
+	return doc, err
+}
+
+// Find 分页查询，page 从 1 开始，size<=0 时不分页；返回当前页数据与总数
+func (r *Repository[T]) Find(ctx context.Context, filter interface{}, page, size int, sort interface{}) ([]T, int64, error) {
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter = r.filter(filter)
+	opts := options.Find()
+	if sort != nil {
+		opts.SetSort(sort)
+	}
+	if size > 0 {
+		if page < 1 {
+			page = 1
+		}
+		opts.SetSkip(int64((page - 1) * size)).SetLimit(int64(size))
+	}
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs := make([]T, 0)
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+	total := int64(len(docs))
+	if size > 0 && (page > 1 || total == int64(size)) {
+		if total, err = coll.CountDocuments(ctx, filter); err != nil {
+			return nil, 0, err
+		}
+	}
+	return docs, total, nil
+}
+
+// Count 统计数量
+func (r *Repository[T]) Count(ctx context.Context, filter interface{}) (int64, error) {
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return coll.CountDocuments(ctx, r.filter(filter))
+}
+
+// Insert 插入一条或多条
+func (r *Repository[T]) Insert(ctx context.Context, docs ...T) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 1 {
+		_, err = coll.InsertOne(ctx, docs[0])
+		return err
+	}
+	_, err = coll.InsertMany(ctx, docs)
+	return err
+}
+
+// Update 更新匹配的文档（update 为 {"$set": ...} 等更新操作），返回匹配数量
+func (r *Repository[T]) Update(ctx context.Context, filter, update interface{}) (int64, error) {
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result, err := coll.UpdateMany(ctx, r.filter(filter), update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+// Upsert 替换匹配的一条文档，不存在时插入；已软删除的文档同样会被替换（即恢复）
+func (r *Repository[T]) Upsert(ctx context.Context, filter interface{}, doc T) error {
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return err
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	_, err = coll.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+// Delete 删除匹配的文档（默认软删除），返回删除数量
+func (r *Repository[T]) Delete(ctx context.Context, filter interface{}) (int64, error) {
+	if r.options.deleteField == "" {
+		return r.HardDelete(ctx, filter)
+	}
+	return r.Update(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: r.options.deleteField, Value: time.Now()}}}})
+}
+
+// HardDelete 物理删除匹配的文档（包括已软删除的）
+func (r *Repository[T]) HardDelete(ctx context.Context, filter interface{}) (int64, error) {
+	coll, err := r.Collection(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	result, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
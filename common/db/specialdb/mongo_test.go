@@ -0,0 +1,54 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type tenantCtxKey struct{}
+
+func TestMongoStoreResolve(t *testing.T) {
+	tenantFunc := func(ctx context.Context) string {
+		tenantID, _ := ctx.Value(tenantCtxKey{}).(string)
+		return tenantID
+	}
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "1001")
+	cases := []struct {
+		mode           string
+		dbName, wantDb string
+		wantColl       string
+	}{
+		{MongoTenantShared, "crm", "crm", "member"},
+		{MongoTenantDatabase, "crm", "crm_1001", "member"},
+		{MongoTenantPrefix, "crm", "crm", "1001_member"},
+	}
+	for _, tc := range cases {
+		store := NewMongoStore(nil, MongoConfig{DbName: tc.dbName, TenantMode: tc.mode}, tenantFunc)
+		dbName, collName, err := store.resolve(ctx, "member")
+		if err != nil || dbName != tc.wantDb || collName != tc.wantColl {
+			t.Fatalf("%q resolve = %s.%s, %v", tc.mode, dbName, collName, err)
+		}
+	}
+	store := NewMongoStore(nil, MongoConfig{DbName: "crm", TenantMode: MongoTenantDatabase}, tenantFunc)
+	if _, _, err := store.resolve(context.Background(), "member"); !errors.Is(err, ErrMongoTenantRequired) {
+		t.Fatalf("未识别租户 err = %v", err)
+	}
+}
+
+func TestRepositoryFilter(t *testing.T) {
+	repo := NewRepository[codecItem](nil, "member")
+	got, err := bson.MarshalExtJSON(repo.filter(bson.M{"shopCode": "S01"}), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"$and":[{"shopCode":"S01"},{"deletedAt":null}]}`; string(got) != want {
+		t.Fatalf("filter = %s, want %s", got, want)
+	}
+	repo = NewRepository[codecItem](nil, "member", RepoSoftDelete(""))
+	if got, _ = bson.MarshalExtJSON(repo.filter(nil), false, false); string(got) != `{}` {
+		t.Fatalf("关闭软删除后 filter = %s", got)
+	}
+}
@@ -0,0 +1,328 @@
+package specialdb
+
+/**
+  二级缓存：进程内 LRU（带过期时间）+ redis，写入或删除时通过 pub/sub 通知所有副本清除本地副本
+  适合读多写少的热点参考数据（门店设置、权限表等）；本地值在副本间共享，调用方不要修改返回值
+    shopCache := specialdb.NewNearCache[ShopSetting](redisTemplate.WithNamespace("crm", tenantID), specialdb.NearCacheConfig{Name: "shop-setting"})
+    go shopCache.Run(ctx) // 订阅失效通知
+    setting, err := shopCache.Fetch(ctx, shopCode, time.Hour, loadShopSetting)
+*/
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NearCacheConfig 二级缓存配置
+type NearCacheConfig struct {
+	Name       string        // 缓存名称，用于失效通知频道
+	MaxEntries int           // 本地最大条目数，默认 10000，超出时淘汰最久未使用的条目
+	LocalTTL   time.Duration // 本地过期时间，默认1分钟（失效通知丢失时的兜底）
+}
+
+// NearCacheStats 二级缓存统计
+type NearCacheStats struct {
+	LocalHits     int64 `json:"localHits"`     // 本地命中
+	RedisHits     int64 `json:"redisHits"`     // 本地未命中、redis 命中
+	Misses        int64 `json:"misses"`        // 均未命中
+	Evictions     int64 `json:"evictions"`     // 超出容量被淘汰
+	Invalidations int64 `json:"invalidations"` // 收到的失效通知
+	Size          int   `json:"size"`          // 当前本地条目数
+}
+
+// nearCacheMessage 失效通知
+type nearCacheMessage struct {
+	Origin string   `json:"origin"` // 发送实例，忽略自己发送的通知
+	Keys   []string `json:"keys,omitempty"`
+	All    bool     `json:"all,omitempty"`
+}
+
+// NearCache 二级缓存
+type NearCache[T any] struct {
+	s       *RedisTemplate
+	config  NearCacheConfig
+	channel string
+	origin  string
+	local   *lruCache[T]
+
+	localHits, redisHits, misses, invalidations atomic.Int64
+}
+
+// NewNearCache 创建二级缓存，需调用 Run 订阅失效通知
+func NewNearCache[T any](s *RedisTemplate, config NearCacheConfig) *NearCache[T] {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = 10000
+	}
+	if config.LocalTTL <= 0 {
+		config.LocalTTL = time.Minute
+	}
+	return &NearCache[T]{
+		s:       s,
+		config:  config,
+		channel: "nearcache:" + config.Name,
+		origin:  uuid.New().String(),
+		local:   newLRUCache[T](config.MaxEntries),
+	}
+}
+
+// Run 订阅失效通知，阻塞直到 ctx 结束；断线重连后清空本地缓存（断开期间的通知已丢失）
+func (c *NearCache[T]) Run(ctx context.Context) error {
+	first := true
+	return c.s.subscribeLoop(ctx, false, []string{c.channel}, c.onMessage, func() {
+		if !first {
+			c.local.clear()
+		}
+		first = false
+	})
+}
+
+func (c *NearCache[T]) onMessage(_ context.Context, msg Message) {
+	var m nearCacheMessage
+	if err := json.Unmarshal(msg.Data, &m); err != nil || m.Origin == c.origin {
+		return
+	}
+	c.invalidations.Add(1)
+	if m.All {
+		c.local.clear()
+		return
+	}
+	for _, key := range m.Keys {
+		c.local.remove(key)
+	}
+}
+
+// Get 依次读取本地、redis，redis 命中时写入本地；都不存在时返回 ErrNotFound
+func (c *NearCache[T]) Get(ctx context.Context, key string) (T, error) {
+	if value, ok := c.local.get(key); ok {
+		c.localHits.Add(1)
+		return value, nil
+	}
+	gen := c.local.begin(key)
+	defer c.local.end(key)
+	value, err := GetAs[T](ctx, c.s, key)
+	if err != nil {
+		if err == ErrNotFound {
+			c.misses.Add(1)
+		}
+		return value, err
+	}
+	c.redisHits.Add(1)
+	c.local.setIf(key, value, c.config.LocalTTL, gen)
+	return value, nil
+}
+
+// Fetch 依次读取本地、redis，均未命中时调用 loader 加载（见 Fetch 的防击穿处理）
+func (c *NearCache[T]) Fetch(ctx context.Context, key string, ttl time.Duration, loader func(ctx context.Context) (T, error), opts ...FetchOption) (T, error) {
+	if value, ok := c.local.get(key); ok {
+		c.localHits.Add(1)
+		return value, nil
+	}
+	gen := c.local.begin(key)
+	defer c.local.end(key)
+	var loaded atomic.Bool
+	value, err := Fetch(ctx, c.s, key, ttl, func(ctx context.Context) (T, error) {
+		loaded.Store(true)
+		return loader(ctx)
+	}, opts...)
+	if err != nil {
+		return value, err
+	}
+	if loaded.Load() {
+		c.misses.Add(1)
+	} else {
+		c.redisHits.Add(1)
+	}
+	c.local.setIf(key, value, c.config.LocalTTL, gen)
+	return value, nil
+}
+
+// Set 写入 redis 与本地，并通知其他副本清除本地副本
+func (c *NearCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := SetAs(ctx, c.s, key, value, ttl); err != nil {
+		c.local.remove(key)
+		return err
+	}
+	c.local.set(key, value, c.config.LocalTTL)
+	return c.publish(ctx, nearCacheMessage{Keys: []string{key}})
+}
+
+// Delete 删除 redis 与本地缓存，并通知其他副本
+func (c *NearCache[T]) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.local.remove(key)
+	}
+	if _, err := c.s.DelMany(ctx, keys...); err != nil {
+		return err
+	}
+	return c.publish(ctx, nearCacheMessage{Keys: keys})
+}
+
+// Invalidate 只清除所有副本的本地缓存（redis 中的数据已由其他方式更新时使用），keys 为空表示全部
+func (c *NearCache[T]) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		c.local.clear()
+		return c.publish(ctx, nearCacheMessage{All: true})
+	}
+	for _, key := range keys {
+		c.local.remove(key)
+	}
+	return c.publish(ctx, nearCacheMessage{Keys: keys})
+}
+
+func (c *NearCache[T]) publish(ctx context.Context, m nearCacheMessage) error {
+	m.Origin = c.origin
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err = c.s.Publish(ctx, c.channel, data); err != nil {
+		return fmt.Errorf("发送缓存失效通知失败: %w", err)
+	}
+	return nil
+}
+
+// Stats 统计信息
+func (c *NearCache[T]) Stats() NearCacheStats {
+	return NearCacheStats{
+		LocalHits:     c.localHits.Load(),
+		RedisHits:     c.redisHits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.local.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Size:          c.local.len(),
+	}
+}
+
+// lruCache 带过期时间的 LRU
+type lruCache[T any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	reads      map[string]*lruRead // 正在从 redis 读取的键
+	evictions  atomic.Int64
+}
+
+// lruRead 读取中的键：gen 在清除该键时递增，读取开始后被清除的值不写入本地
+type lruRead struct {
+	refs int
+	gen  uint64
+}
+
+type lruEntry[T any] struct {
+	key      string
+	value    T
+	expireAt time.Time
+}
+
+func newLRUCache[T any](maxEntries int) *lruCache[T] {
+	return &lruCache[T]{maxEntries: maxEntries, ll: list.New(), items: make(map[string]*list.Element), reads: make(map[string]*lruRead)}
+}
+
+// begin 开始从 redis 读取 key，返回当前代数；须调用 end 结束
+func (l *lruCache[T]) begin(key string) uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.reads[key]
+	if !ok {
+		r = &lruRead{}
+		l.reads[key] = r
+	}
+	r.refs++
+	return r.gen
+}
+
+func (l *lruCache[T]) end(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.reads[key]; ok {
+		if r.refs--; r.refs <= 0 {
+			delete(l.reads, key)
+		}
+	}
+}
+
+// setIf 读取期间 key 未被清除时写入，避免失效通知之后写回旧值
+func (l *lruCache[T]) setIf(key string, value T, ttl time.Duration, gen uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.reads[key]; ok && r.gen != gen {
+		return
+	}
+	l.setLocked(key, value, ttl)
+}
+
+func (l *lruCache[T]) get(key string) (value T, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return value, false
+	}
+	entry := elem.Value.(*lruEntry[T])
+	if time.Now().After(entry.expireAt) {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+		return value, false
+	}
+	l.ll.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (l *lruCache[T]) set(key string, value T, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.setLocked(key, value, ttl)
+}
+
+func (l *lruCache[T]) setLocked(key string, value T, ttl time.Duration) {
+	expireAt := time.Now().Add(ttl)
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*lruEntry[T])
+		entry.value, entry.expireAt = value, expireAt
+		l.ll.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.ll.PushFront(&lruEntry[T]{key: key, value: value, expireAt: expireAt})
+	for l.ll.Len() > l.maxEntries {
+		oldest := l.ll.Back()
+		l.ll.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry[T]).key)
+		l.evictions.Add(1)
+	}
+}
+
+func (l *lruCache[T]) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.reads[key]; ok {
+		r.gen++
+	}
+	if elem, ok := l.items[key]; ok {
+		l.ll.Remove(elem)
+		delete(l.items, key)
+	}
+}
+
+func (l *lruCache[T]) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ll.Init()
+	l.items = make(map[string]*list.Element)
+	for _, r := range l.reads {
+		r.gen++
+	}
+}
+
+func (l *lruCache[T]) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ll.Len()
+}
@@ -0,0 +1,125 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNearCache(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := NearCacheConfig{Name: "shop", MaxEntries: 2}
+	a := NewNearCache[codecItem](s, config)
+	b := NewNearCache[codecItem](s, config)
+	go a.Run(ctx)
+	go b.Run(ctx)
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.PubSubNumSub("nearcache:shop")["nearcache:shop"] < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := b.Get(ctx, "s1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get 不存在的键 err = %v", err)
+	}
+	if err := a.Set(ctx, "s1", codecItem{ID: 1, Name: "旧"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	waitInvalidations(t, b, 1)
+	if v, err := b.Get(ctx, "s1"); err != nil || v.Name != "旧" {
+		t.Fatalf("Get = %+v, %v", v, err)
+	}
+	if _, err := b.Get(ctx, "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if st := b.Stats(); st.LocalHits != 1 || st.RedisHits != 1 || st.Misses != 1 || st.Size != 1 {
+		t.Fatalf("Stats = %+v", st)
+	}
+
+	// a 写入后 b 的本地副本被清除
+	if err := a.Set(ctx, "s1", codecItem{ID: 1, Name: "新"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	waitInvalidations(t, b, 2)
+	if v, err := b.Get(ctx, "s1"); err != nil || v.Name != "新" {
+		t.Fatalf("失效通知后 Get = %+v, %v", v, err)
+	}
+	if st := a.Stats(); st.Invalidations != 0 {
+		t.Fatalf("不应处理自己发送的通知: %+v", st)
+	}
+
+	// 超出容量淘汰最久未使用的条目
+	for _, key := range []string{"s2", "s3"} {
+		if _, err := b.Fetch(ctx, key, time.Minute, func(ctx context.Context) (codecItem, error) {
+			return codecItem{Name: key}, nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := b.Stats(); st.Size != 2 || st.Evictions != 1 || st.Misses != 3 {
+		t.Fatalf("Stats = %+v", st)
+	}
+
+	if err := a.Delete(ctx, "s2"); err != nil {
+		t.Fatal(err)
+	}
+	waitInvalidations(t, b, 3)
+	if _, err := b.Get(ctx, "s2"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete 后 Get err = %v", err)
+	}
+
+	// Fetch 缓存的“不存在”，Get 同样返回 ErrNotFound
+	if _, err := a.Fetch(ctx, "s4", time.Minute, func(ctx context.Context) (codecItem, error) {
+		return codecItem{}, ErrNotFound
+	}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Fetch 不存在 err = %v", err)
+	}
+	if _, err := b.Get(ctx, "s4"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get 不存在占位 err = %v", err)
+	}
+}
+
+func TestNearCacheStaleWrite(t *testing.T) {
+	l := newLRUCache[string](10)
+
+	// 读取期间收到失效通知，读到的旧值不写入本地
+	gen := l.begin("k")
+	l.remove("k")
+	l.setIf("k", "旧", time.Minute, gen)
+	l.end("k")
+	if _, ok := l.get("k"); ok {
+		t.Fatal("失效后不应写入读取中的旧值")
+	}
+	gen = l.begin("k")
+	l.clear()
+	l.setIf("k", "旧", time.Minute, gen)
+	l.end("k")
+	if _, ok := l.get("k"); ok {
+		t.Fatal("清空后不应写入读取中的旧值")
+	}
+
+	gen = l.begin("k")
+	l.remove("other")
+	l.setIf("k", "新", time.Minute, gen)
+	l.end("k")
+	if v, ok := l.get("k"); !ok || v != "新" {
+		t.Fatalf("get = %q, %v", v, ok)
+	}
+	if len(l.reads) != 0 {
+		t.Fatalf("读取结束后应清理: %d", len(l.reads))
+	}
+}
+
+func waitInvalidations(t *testing.T, c *NearCache[codecItem], n int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Invalidations < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("未收到失效通知: %+v", c.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
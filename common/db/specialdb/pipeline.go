@@ -0,0 +1,475 @@
+package specialdb
+
+/**
+  管道与事务：命令先入队，Exec 时一次发送并读取全部结果，减少往返
+    p := redisTemplate.Pipeline()        // TxPipeline() 使用 MULTI/EXEC
+    get := p.Get("member:1")
+    p.Set("member:2", member, 10*time.Minute)
+    if err := p.Exec(ctx); err != nil { ... }
+    data, err := get.Bytes()
+  集群模式下管道按节点分组发送（每个节点一次往返）；事务要求所有键位于同一槽位（可使用 {hash tag}）
+*/
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
+)
+
+// ErrTxAborted 事务被放弃（EXEC 返回 nil）
+var ErrTxAborted = errors.New("redis: 事务已放弃")
+
+// ErrCrossSlot 集群模式下事务中的键不在同一槽位
+var ErrCrossSlot = errors.New("redis: 集群模式事务中的键必须位于同一槽位")
+
+// Pipeline 命令管道，非并发安全
+type Pipeline struct {
+	s    *RedisTemplate
+	tx   bool
+	cmds []*PipelineCmd
+}
+
+// PipelineCmd 入队的命令，Exec 后取得结果
+type PipelineCmd struct {
+	s     *RedisTemplate
+	name  string
+	keys  []string // 完整键（已含命名空间）
+	args  []interface{}
+	reply interface{}
+	err   error
+	done  bool
+}
+
+// Pipeline 创建命令管道
+func (s *RedisTemplate) Pipeline() *Pipeline {
+	return &Pipeline{s: s}
+}
+
+// TxPipeline 创建事务管道（MULTI/EXEC），命令全部执行或全部不执行
+func (s *RedisTemplate) TxPipeline() *Pipeline {
+	return &Pipeline{s: s, tx: true}
+}
+
+// Do 入队任意单键命令：cmd key args...（key 自动加上命名空间）
+func (p *Pipeline) Do(cmd, key string, args ...interface{}) *PipelineCmd {
+	return p.add(cmd, []string{p.s.Key(key)}, args)
+}
+
+// Get 入队 GET
+func (p *Pipeline) Get(key string) *PipelineCmd {
+	return p.Do("GET", key)
+}
+
+// Set 入队 SET，value 为 []byte、string 时原样写入，其他类型按 Codec 编码；ttl<=0 表示不过期
+func (p *Pipeline) Set(key string, value interface{}, ttl time.Duration) *PipelineCmd {
+	data, err := p.s.encode(value)
+	if err != nil {
+		cmd := p.add("SET", []string{p.s.Key(key)}, nil)
+		cmd.err = &RedisError{Op: "encode", Key: cmd.keys[0], Err: err}
+		cmd.done = true
+		return cmd
+	}
+	return p.Do("SET", key, append([]interface{}{data}, expireArgs(ttl)...)...)
+}
+
+// Del 入队 DEL
+func (p *Pipeline) Del(key string) *PipelineCmd {
+	return p.Do("DEL", key)
+}
+
+// Expire 入队过期时间设置（毫秒精度）
+func (p *Pipeline) Expire(key string, ttl time.Duration) *PipelineCmd {
+	return p.Do("PEXPIRE", key, ttl.Milliseconds())
+}
+
+// IncrBy 入队 INCRBY
+func (p *Pipeline) IncrBy(key string, n int64) *PipelineCmd {
+	return p.Do("INCRBY", key, n)
+}
+
+// Len 已入队命令数
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+func (p *Pipeline) add(name string, keys []string, args []interface{}) *PipelineCmd {
+	cmd := &PipelineCmd{s: p.s, name: name, keys: keys, args: args}
+	p.cmds = append(p.cmds, cmd)
+	return cmd
+}
+
+// Exec 发送全部命令并读取结果，返回连接错误或第一个命令错误；各命令结果通过 PipelineCmd 取得
+// Exec 后管道清空，可继续入队复用
+func (p *Pipeline) Exec(ctx context.Context) error {
+	cmds := make([]*PipelineCmd, 0, len(p.cmds))
+	for _, cmd := range p.cmds {
+		if !cmd.done {
+			cmds = append(cmds, cmd)
+		}
+	}
+	all := p.cmds
+	p.cmds = nil
+
+	groups := [][]*PipelineCmd{cmds}
+	if p.s.Cluster != nil {
+		if p.tx {
+			if groups = groupBySlot(cmds); len(groups) > 1 {
+				return ErrCrossSlot
+			}
+		} else {
+			groups = p.s.groupByNode(cmds)
+		}
+	}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		if err := p.s.execGroup(ctx, group, p.tx); err != nil {
+			for _, cmd := range group {
+				if !cmd.done {
+					cmd.err, cmd.done = err, true
+				}
+			}
+			return err
+		}
+	}
+	for _, cmd := range all {
+		if cmd.err != nil {
+			return cmd.err
+		}
+	}
+	return nil
+}
+
+// groupBySlot 按第一个键的槽位分组，保持组内顺序
+func groupBySlot(cmds []*PipelineCmd) [][]*PipelineCmd {
+	index := make(map[int]int)
+	var groups [][]*PipelineCmd
+	for _, cmd := range cmds {
+		slot := -1
+		if len(cmd.keys) > 0 {
+			slot = redisc.Slot(cmd.keys[0])
+		}
+		i, ok := index[slot]
+		if !ok {
+			i = len(groups)
+			index[slot] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], cmd)
+	}
+	return groups
+}
+
+// clusterLayout 集群槽位对应的主节点地址，由 redisc 刷新拓扑（启动及 MOVED 后）时更新
+type clusterLayout struct {
+	addrs atomic.Pointer[[redisc.HashSlots]string]
+}
+
+// update 作为 redisc.Cluster.LayoutRefresh 回调
+func (l *clusterLayout) update(_, mapping [redisc.HashSlots][]string) {
+	var addrs [redisc.HashSlots]string
+	for slot, nodes := range mapping {
+		if len(nodes) > 0 {
+			addrs[slot] = nodes[0]
+		}
+	}
+	l.addrs.Store(&addrs)
+}
+
+// addr 槽位所在主节点地址，未知时返回空
+func (l *clusterLayout) addr(slot int) string {
+	if l == nil {
+		return ""
+	}
+	addrs := l.addrs.Load()
+	if addrs == nil {
+		return ""
+	}
+	return addrs[slot]
+}
+
+// groupByNode 按第一个键所在节点分组，保持组内顺序；节点未知时按槽位分组
+// 连接绑定到组内第一个键的节点，同一节点上不同槽位的命令一次发送；槽位迁移中的键返回 MOVED 错误
+func (s *RedisTemplate) groupByNode(cmds []*PipelineCmd) [][]*PipelineCmd {
+	index := make(map[string]int)
+	var groups [][]*PipelineCmd
+	for _, cmd := range cmds {
+		node := ""
+		if len(cmd.keys) > 0 {
+			slot := redisc.Slot(cmd.keys[0])
+			if node = s.layout.addr(slot); node == "" {
+				node = "slot:" + strconv.Itoa(slot)
+			}
+		}
+		i, ok := index[node]
+		if !ok {
+			i = len(groups)
+			index[node] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], cmd)
+	}
+	return groups
+}
+
+// execGroup 在同一连接上发送一组命令；以 Do("") 一次刷新并读取全部结果，链路追踪通过 buildArgs 传入 ctx
+func (s *RedisTemplate) execGroup(ctx context.Context, cmds []*PipelineCmd, tx bool) error {
+	var conn redis.Conn
+	if s.Cluster == nil {
+		conn = s.Pool.Get()
+	} else {
+		// 管道不能使用 RetryConn，MOVED 错误作为命令错误返回，集群拓扑随后自动刷新
+		conn = s.Cluster.Get()
+		if len(cmds[0].keys) > 0 {
+			if err := redisc.BindConn(conn, cmds[0].keys[0]); err != nil {
+				safeClose(conn, "Pipeline")
+				return err
+			}
+		}
+	}
+	defer safeClose(conn, "Pipeline")
+
+	if tx {
+		if err := conn.Send("MULTI"); err != nil {
+			return err
+		}
+	}
+	for _, cmd := range cmds {
+		args := make([]interface{}, 0, len(cmd.keys)+len(cmd.args))
+		for _, key := range cmd.keys {
+			args = append(args, key)
+		}
+		if err := conn.Send(cmd.name, append(args, cmd.args...)...); err != nil {
+			return err
+		}
+	}
+	if tx {
+		if err := conn.Send("EXEC"); err != nil {
+			return err
+		}
+	}
+	replies, err := redis.Values(conn.Do("", s.buildArgs(ctx, nil)...))
+	if err != nil {
+		return err
+	}
+
+	if tx {
+		// MULTI、QUEUED... 之后为 EXEC 结果
+		if len(replies) != len(cmds)+2 {
+			return errors.New("redis: 事务返回结果数量不匹配")
+		}
+		switch exec := replies[len(replies)-1].(type) {
+		case nil:
+			return ErrTxAborted
+		case redis.Error:
+			// 入队阶段出错（EXECABORT），记录各命令的入队错误
+			for i, cmd := range cmds {
+				if queued, ok := replies[i+1].(redis.Error); ok {
+					cmd.err, cmd.done = cmd.wrap(queued), true
+				}
+			}
+			return exec
+		case []interface{}:
+			replies = exec
+		default:
+			return errors.New("redis: 无法识别的事务返回结果")
+		}
+	}
+	if len(replies) != len(cmds) {
+		return errors.New("redis: 管道返回结果数量不匹配")
+	}
+	for i, cmd := range cmds {
+		cmd.reply, cmd.done = replies[i], true
+		if e, ok := replies[i].(redis.Error); ok {
+			cmd.reply, cmd.err = nil, cmd.wrap(e)
+		}
+	}
+	return nil
+}
+
+func (c *PipelineCmd) wrap(err error) error {
+	key := ""
+	if len(c.keys) > 0 {
+		key = c.keys[0]
+	}
+	return wrapErr(c.name, key, err)
+}
+
+// Err 命令错误，键不存在不视为错误
+func (c *PipelineCmd) Err() error {
+	if !c.done {
+		return errors.New("redis: 管道尚未执行")
+	}
+	return c.err
+}
+
+// Reply 原始结果
+func (c *PipelineCmd) Reply() (interface{}, error) {
+	return c.reply, c.Err()
+}
+
+// Bytes 结果转为 []byte，键不存在时返回 ErrNotFound
+func (c *PipelineCmd) Bytes() ([]byte, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	v, err := redis.Bytes(c.reply, nil)
+	return v, c.wrap(err)
+}
+
+// String 结果转为 string，键不存在时返回 ErrNotFound
+func (c *PipelineCmd) String() (string, error) {
+	if err := c.Err(); err != nil {
+		return "", err
+	}
+	v, err := redis.String(c.reply, nil)
+	return v, c.wrap(err)
+}
+
+// Int64 结果转为 int64
+func (c *PipelineCmd) Int64() (int64, error) {
+	if err := c.Err(); err != nil {
+		return 0, err
+	}
+	v, err := redis.Int64(c.reply, nil)
+	return v, c.wrap(err)
+}
+
+// Bool 结果转为 bool
+func (c *PipelineCmd) Bool() (bool, error) {
+	if err := c.Err(); err != nil {
+		return false, err
+	}
+	v, err := redis.Bool(c.reply, nil)
+	return v, c.wrap(err)
+}
+
+// Strings 结果转为 []string
+func (c *PipelineCmd) Strings() ([]string, error) {
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	v, err := redis.Strings(c.reply, nil)
+	return v, c.wrap(err)
+}
+
+// Decode 按 Codec 解码结果，键不存在时返回 ErrNotFound
+func (c *PipelineCmd) Decode(v interface{}) error {
+	data, err := c.Bytes()
+	if err != nil {
+		return err
+	}
+	if err = c.s.codec().Unmarshal(data, v); err != nil {
+		return &RedisError{Op: "decode", Key: c.keys[0], Err: err}
+	}
+	return nil
+}
+
+// encode []byte、string 原样写入，其他类型按 Codec 编码
+func (s *RedisTemplate) encode(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return s.codec().Marshal(value)
+	}
+}
+
+// MSetItem 批量写入项
+type MSetItem struct {
+	Key   string
+	Value interface{}   // []byte、string 原样写入，其他类型按 Codec 编码
+	TTL   time.Duration // 过期时间，<=0 表示不过期
+}
+
+// MSet 批量写入（每个键可设置不同的过期时间），一次管道发送
+func (s *RedisTemplate) MSet(ctx context.Context, items ...MSetItem) error {
+	p := s.Pipeline()
+	for _, item := range items {
+		p.Set(item.Key, item.Value, item.TTL)
+	}
+	return p.Exec(ctx)
+}
+
+// MGet 批量读取原始值，返回存在的键（不含命名空间）与值；集群模式按槽位拆分 MGET，同一节点的一次管道发送
+func (s *RedisTemplate) MGet(ctx context.Context, keys ...string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+	fullKeys := make([]string, len(keys))
+	origin := make(map[string]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = s.Key(key)
+		origin[fullKeys[i]] = key
+	}
+	if s.Cluster == nil {
+		values, err := s.mgetRaw(ctx, fullKeys)
+		if err != nil {
+			return result, err
+		}
+		for i, data := range values {
+			if data != nil {
+				result[origin[fullKeys[i]]] = data
+			}
+		}
+		return result, nil
+	}
+	groups := redisc.SplitBySlot(fullKeys...)
+	p := s.Pipeline()
+	cmds := make([]*PipelineCmd, len(groups))
+	for i, group := range groups {
+		cmds[i] = p.add("MGET", group, nil)
+	}
+	if err := p.Exec(ctx); err != nil {
+		return result, err
+	}
+	for i, cmd := range cmds {
+		values, err := redis.ByteSlices(cmd.reply, nil)
+		if err != nil {
+			return result, &RedisError{Op: "MGET", Key: strings.Join(groups[i], ","), Err: err}
+		}
+		for j, data := range values {
+			if data != nil {
+				result[origin[groups[i][j]]] = data
+			}
+		}
+	}
+	return result, nil
+}
+
+// DelMany 批量删除，返回删除的键数；集群模式按槽位拆分 DEL，同一节点的一次管道发送
+func (s *RedisTemplate) DelMany(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = s.Key(key)
+	}
+	groups := [][]string{fullKeys}
+	if s.Cluster != nil {
+		groups = redisc.SplitBySlot(fullKeys...)
+	}
+	p := s.Pipeline()
+	cmds := make([]*PipelineCmd, len(groups))
+	for i, group := range groups {
+		cmds[i] = p.add("DEL", group, nil)
+	}
+	err := p.Exec(ctx)
+	var deleted int64
+	for _, cmd := range cmds {
+		n, _ := cmd.Int64()
+		deleted += n
+	}
+	return deleted, err
+}
@@ -0,0 +1,156 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestPipeline(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	cache := s.WithNamespace("crm")
+	_ = mr.Set("crm:name", "张三")
+	_ = mr.Set("crm:text", "abc")
+
+	p := cache.Pipeline()
+	name := p.Get("name")
+	missing := p.Get("missing")
+	set := p.Set("member", codecItem{ID: 1}, time.Minute)
+	incr := p.IncrBy("counter", 5)
+	bad := p.IncrBy("text", 1)
+	if p.Len() != 5 {
+		t.Fatalf("Len = %d", p.Len())
+	}
+	if name.Err() == nil {
+		t.Fatal("未执行时应返回错误")
+	}
+	if err := p.Exec(ctx); err == nil {
+		t.Fatal("应返回第一个命令错误")
+	}
+	if v, err := name.String(); err != nil || v != "张三" {
+		t.Fatalf("GET = %s, %v", v, err)
+	}
+	if _, err := missing.Bytes(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GET 不存在 err = %v", err)
+	}
+	if err := set.Err(); err != nil || mr.TTL("crm:member") != time.Minute {
+		t.Fatalf("SET err = %v, ttl = %v", err, mr.TTL("crm:member"))
+	}
+	if n, err := incr.Int64(); err != nil || n != 5 {
+		t.Fatalf("INCRBY = %d, %v", n, err)
+	}
+	var redisErr *RedisError
+	if err := bad.Err(); !errors.As(err, &redisErr) || redisErr.Key != "crm:text" {
+		t.Fatalf("INCRBY 错误 = %v", err)
+	}
+
+	p.Get("member")
+	var item codecItem
+	get := p.Get("member")
+	if err := p.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := get.Decode(&item); err != nil || item.ID != 1 {
+		t.Fatalf("Decode = %v, %v", item, err)
+	}
+}
+
+func TestTxPipeline(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+
+	p := s.TxPipeline()
+	p.Set("a", "1", 0)
+	incr := p.IncrBy("a", 2)
+	if err := p.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := incr.Int64(); n != 3 {
+		t.Fatalf("事务 INCRBY = %d", n)
+	}
+
+	// 入队错误时整个事务不执行
+	p.Set("b", "1", 0)
+	p.Do("SET", "c")
+	if err := p.Exec(ctx); err == nil {
+		t.Fatal("入队错误应返回错误")
+	}
+	if mr.Exists("b") {
+		t.Fatal("事务放弃后不应写入")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	cache := s.WithNamespace("crm")
+
+	err := cache.MSet(ctx,
+		MSetItem{Key: "a", Value: "1"},
+		MSetItem{Key: "b", Value: []byte("2"), TTL: time.Minute},
+		MSetItem{Key: "c", Value: codecItem{ID: 3}, TTL: 1500 * time.Millisecond},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mr.TTL("crm:a") != 0 || mr.TTL("crm:b") != time.Minute || mr.TTL("crm:c") != 1500*time.Millisecond {
+		t.Fatal("MSet 过期时间不正确")
+	}
+	values, err := cache.MGet(ctx, "a", "b", "x")
+	if err != nil || len(values) != 2 || string(values["b"]) != "2" {
+		t.Fatalf("MGet = %v, %v", values, err)
+	}
+	items, err := MGet[codecItem](ctx, cache, "c")
+	if err != nil || items["c"].ID != 3 {
+		t.Fatalf("MGet[T] = %v, %v", items, err)
+	}
+	deleted, err := cache.DelMany(ctx, "a", "b", "x")
+	if err != nil || deleted != 2 || mr.Exists("crm:a") || !mr.Exists("crm:c") {
+		t.Fatalf("DelMany = %d, %v", deleted, err)
+	}
+}
+
+func TestPipelineCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s, err := ConnRedis(RedisConfig{Mode: RedisCluster, ClusterNodes: []string{mr.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	// 非事务管道按节点分组：不同槽位的键在同一节点上一次发送
+	p := s.Pipeline()
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = "member:" + strconv.Itoa(i)
+		p.Set(keys[i], i, time.Minute)
+	}
+	if groups := s.groupByNode(p.cmds); len(groups) != 1 {
+		t.Fatalf("节点分组数 = %d, want 1", len(groups))
+	}
+	if err = p.Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	values, err := s.MGet(ctx, append(keys, "missing")...)
+	if err != nil || len(values) != len(keys) || string(values["member:42"]) != "42" {
+		t.Fatalf("MGet = %d, %v", len(values), err)
+	}
+	deleted, err := s.DelMany(ctx, keys...)
+	if err != nil || deleted != int64(len(keys)) || len(mr.Keys()) != 0 {
+		t.Fatalf("DelMany = %d, %v", deleted, err)
+	}
+
+	// 事务仍要求同一槽位
+	tx := s.TxPipeline()
+	tx.Set("a", "1", 0)
+	tx.Set("b", "1", 0)
+	if err = tx.Exec(ctx); !errors.Is(err, ErrCrossSlot) {
+		t.Fatalf("跨槽位事务 err = %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package specialdb
+
+/**
+  发布订阅：用于跨副本的轻量通知（缓存失效、租户配置重载等），消息不持久化，订阅断开期间的消息会丢失
+    go redisTemplate.Subscribe(ctx, []string{"tenant:reload"}, func(ctx context.Context, msg specialdb.Message) { ... })
+    redisTemplate.Publish(ctx, "tenant:reload", tenantID)
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Message 订阅收到的消息
+type Message struct {
+	Channel string // 频道（不含命名空间）
+	Pattern string // PSubscribe 匹配的模式（不含命名空间）
+	Data    []byte
+}
+
+// pubsubHealthCheck 订阅连接的心跳间隔，超过两倍间隔未收到任何回复视为断开
+const pubsubHealthCheck = 30 * time.Second
+
+// Publish 发布消息，返回收到消息的订阅者数；message 为 []byte、string 时原样发送，其他类型按 Codec 编码
+func (s *RedisTemplate) Publish(ctx context.Context, channel string, message interface{}) (int64, error) {
+	data, err := s.encode(message)
+	if err != nil {
+		return 0, &RedisError{Op: "encode", Key: s.Key(channel), Err: err}
+	}
+	return doAs(s, redis.Int64, "PUBLISH", channel, []interface{}{data}, ctx)
+}
+
+// Subscribe 订阅频道并阻塞处理消息，连接断开时自动重连（退避 1 秒至 30 秒），直到 ctx 结束
+// handler 在接收协程中顺序调用，耗时处理请自行异步
+func (s *RedisTemplate) Subscribe(ctx context.Context, channels []string, handler func(ctx context.Context, msg Message)) error {
+	return s.subscribeLoop(ctx, false, channels, handler, nil)
+}
+
+// PSubscribe 按模式订阅（如 "tenant:*"），其余同 Subscribe
+func (s *RedisTemplate) PSubscribe(ctx context.Context, patterns []string, handler func(ctx context.Context, msg Message)) error {
+	return s.subscribeLoop(ctx, true, patterns, handler, nil)
+}
+
+// subscribeLoop 订阅并自动重连，每次订阅成功后调用 onSubscribed（可为 nil），用于重连后处理断开期间丢失的消息
+func (s *RedisTemplate) subscribeLoop(ctx context.Context, pattern bool, channels []string, handler func(ctx context.Context, msg Message), onSubscribed func()) error {
+	if len(channels) == 0 {
+		return errors.New("redis: 订阅频道不能为空")
+	}
+	backoff := time.Second
+	for {
+		subscribed, err := s.subscribe(ctx, pattern, channels, handler, onSubscribed)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if subscribed {
+			// 订阅成功过，说明连接可用，重新从最短间隔开始重连
+			backoff = time.Second
+		}
+		fmt.Printf("------------RedisTemplate: Subscribe %v 断开，%v 后重连:%v------------\n", channels, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// subscribe 单次订阅，返回是否订阅成功以及断开原因
+func (s *RedisTemplate) subscribe(ctx context.Context, pattern bool, channels []string, handler func(ctx context.Context, msg Message), onSubscribed func()) (subscribed bool, err error) {
+	// 集群模式使用普通（非重试）连接，订阅命令需要 Send
+	var conn redis.Conn
+	if s.Cluster != nil {
+		conn = s.Cluster.Get()
+	} else {
+		conn = s.Pool.Get()
+	}
+	psc := redis.PubSubConn{Conn: conn}
+	defer psc.Close()
+
+	args := make([]interface{}, len(channels))
+	for i, channel := range channels {
+		args[i] = s.Key(channel)
+	}
+	if pattern {
+		err = psc.PSubscribe(args...)
+	} else {
+		err = psc.Subscribe(args...)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// ctx 结束时退订，使 Receive 返回；定时 PING 检测半开连接
+	// 返回前等待该协程退出，避免与 psc.Close 并发使用连接
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(done)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pubsubHealthCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if pattern {
+					_ = psc.PUnsubscribe()
+				} else {
+					_ = psc.Unsubscribe()
+				}
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	prefix := s.Key("")
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * pubsubHealthCheck).(type) {
+		case redis.Message:
+			msg := Message{
+				Channel: strings.TrimPrefix(v.Channel, prefix),
+				Pattern: strings.TrimPrefix(v.Pattern, prefix),
+				Data:    v.Data,
+			}
+			s.handleMessage(ctx, msg, handler)
+		case redis.Subscription:
+			if (v.Kind == "subscribe" || v.Kind == "psubscribe") && !subscribed {
+				subscribed = true
+				if onSubscribed != nil {
+					onSubscribed()
+				}
+			}
+			if v.Count == 0 {
+				return subscribed, ctx.Err()
+			}
+		case error:
+			return subscribed, v
+		}
+	}
+}
+
+func (s *RedisTemplate) handleMessage(ctx context.Context, msg Message, handler func(ctx context.Context, msg Message)) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("------------RedisTemplate: Subscribe %s 处理消息异常:%v------------\n", msg.Channel, r)
+		}
+	}()
+	handler(ctx, msg)
+}
@@ -0,0 +1,168 @@
+package specialdb
+
+/**
+  基于 redis 的分布式限流（多副本共享计数），限流判断在 Lua 脚本中原子完成，时间取 redis 服务端时钟
+    滑动窗口：window 内最多 limit 次请求，计数精确，适合低频接口
+    令牌桶：每秒补充 rate 个令牌，最多积累 burst 个，允许短时突发
+    limiter, err := redisTemplate.NewTokenBucketLimiter(100, 200)
+    result, err := limiter.Allow(ctx, "tenant:"+tenantID)
+*/
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+)
+
+// LimitResult 限流判断结果
+type LimitResult struct {
+	Allowed    bool
+	Limit      int64         // 窗口内最大请求数（令牌桶为 burst）
+	Remaining  int64         // 剩余可用次数
+	RetryAfter time.Duration // 被限流时距离下次允许的时间
+}
+
+// Limiter 限流器
+type Limiter interface {
+	// Allow 消耗一次额度
+	Allow(ctx context.Context, key string) (LimitResult, error)
+	// AllowN 消耗 n 次额度，额度不足时不消耗
+	AllowN(ctx context.Context, key string, n int64) (LimitResult, error)
+}
+
+var (
+	_ Limiter = (*SlidingWindowLimiter)(nil)
+	_ Limiter = (*TokenBucketLimiter)(nil)
+)
+
+// 限流键前缀
+const rateLimitPrefix = "ratelimit:"
+
+// ErrInvalidLimit 限流参数不合法
+var ErrInvalidLimit = errors.New("ratelimit: 限流参数必须大于 0")
+
+// KEYS[1] 计数 zset；ARGV[1] 窗口（毫秒），ARGV[2] 上限，ARGV[3] 本次请求数，ARGV[4] 成员前缀
+// 返回 {是否允许, 剩余次数, 重试等待（毫秒）}
+const slidingWindowScript = luaNow + `
+	local window = tonumber(ARGV[1])
+	local limit = tonumber(ARGV[2])
+	local n = tonumber(ARGV[3])
+	redis.call("zremrangebyscore", KEYS[1], "-inf", now - window)
+	local count = redis.call("zcard", KEYS[1])
+	if count + n <= limit then
+		for i = 1, n do
+			redis.call("zadd", KEYS[1], now, ARGV[4] .. ":" .. i)
+		end
+		redis.call("pexpire", KEYS[1], window)
+		return {1, limit - count - n, 0}
+	end
+	local retry = -1
+	if n <= limit then
+		-- 需要等到第 count+n-limit 个最早的请求移出窗口
+		retry = window
+		local oldest = redis.call("zrange", KEYS[1], count + n - limit - 1, count + n - limit - 1, "WITHSCORES")
+		if #oldest == 2 then
+			retry = tonumber(oldest[2]) + window - now
+		end
+	end
+	return {0, math.max(limit - count, 0), retry}
+`
+
+// KEYS[1] 令牌桶 hash；ARGV[1] 每秒补充令牌数，ARGV[2] 容量，ARGV[3] 本次请求数
+// 返回 {是否允许, 剩余令牌, 重试等待（毫秒）}
+const tokenBucketScript = luaNow + `
+	local rate = tonumber(ARGV[1])
+	local burst = tonumber(ARGV[2])
+	local n = tonumber(ARGV[3])
+	local bucket = redis.call("hmget", KEYS[1], "tokens", "ts")
+	local tokens = tonumber(bucket[1])
+	local ts = tonumber(bucket[2])
+	if tokens == nil then
+		tokens = burst
+		ts = now
+	end
+	tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+	local allowed = 0
+	local retry = 0
+	if tokens >= n then
+		tokens = tokens - n
+		allowed = 1
+	elseif n <= burst then
+		retry = math.ceil((n - tokens) * 1000 / rate)
+	else
+		retry = -1
+	end
+	redis.call("hset", KEYS[1], "tokens", tostring(tokens), "ts", now)
+	redis.call("pexpire", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+	return {allowed, math.floor(tokens), retry}
+`
+
+// SlidingWindowLimiter 滑动窗口限流
+type SlidingWindowLimiter struct {
+	s      *RedisTemplate
+	limit  int64
+	window time.Duration
+}
+
+// NewSlidingWindowLimiter 创建滑动窗口限流：window（至少 1 毫秒）内最多 limit 次，参数不合法时返回 ErrInvalidLimit
+func (s *RedisTemplate) NewSlidingWindowLimiter(limit int64, window time.Duration) (*SlidingWindowLimiter, error) {
+	if limit <= 0 || window < time.Millisecond {
+		return nil, ErrInvalidLimit
+	}
+	return &SlidingWindowLimiter{s: s, limit: limit, window: window}, nil
+}
+
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (LimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *SlidingWindowLimiter) AllowN(ctx context.Context, key string, n int64) (LimitResult, error) {
+	args := []interface{}{l.window.Milliseconds(), l.limit, n, uuid.New().String()}
+	return l.s.evalLimit(slidingWindowScript, key, args, l.limit, ctx)
+}
+
+// TokenBucketLimiter 令牌桶限流
+type TokenBucketLimiter struct {
+	s     *RedisTemplate
+	rate  float64
+	burst int64
+}
+
+// NewTokenBucketLimiter 创建令牌桶限流：每秒补充 rate 个令牌，最多积累 burst 个，参数不合法时返回 ErrInvalidLimit
+func (s *RedisTemplate) NewTokenBucketLimiter(rate float64, burst int64) (*TokenBucketLimiter, error) {
+	// rate 为 0 时脚本计算重试等待与过期时间会除以 0
+	if !(rate > 0) || math.IsInf(rate, 1) || burst <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	return &TokenBucketLimiter{s: s, rate: rate, burst: burst}, nil
+}
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (LimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int64) (LimitResult, error) {
+	args := []interface{}{strconv.FormatFloat(l.rate, 'f', -1, 64), l.burst, n}
+	return l.s.evalLimit(tokenBucketScript, key, args, l.burst, ctx)
+}
+
+// evalLimit 执行限流脚本并解析 {是否允许, 剩余, 重试等待（毫秒）}，重试等待为负数表示请求数超过上限，永远不会被允许
+func (s *RedisTemplate) evalLimit(script, key string, args []interface{}, limit int64, ctx context.Context) (LimitResult, error) {
+	values, err := redis.Int64s(s.EvalLuaScript(script, []string{rateLimitPrefix + key}, args, ctx))
+	if err != nil {
+		return LimitResult{}, wrapErr("ratelimit", s.Key(rateLimitPrefix+key), err)
+	}
+	if len(values) != 3 {
+		return LimitResult{}, &RedisError{Op: "ratelimit", Key: s.Key(rateLimitPrefix + key), Err: errors.New("限流脚本返回结果异常")}
+	}
+	result := LimitResult{Allowed: values[0] == 1, Limit: limit, Remaining: values[1]}
+	if !result.Allowed && values[2] > 0 {
+		result.RetryAfter = time.Duration(values[2]) * time.Millisecond
+	}
+	return result, nil
+}
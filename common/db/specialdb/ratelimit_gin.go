@@ -0,0 +1,124 @@
+package specialdb
+
+/**
+  gin 限流中间件：按租户、门店、IP 或自定义维度限流，可按接口区分，被限流时返回 429 与 Retry-After
+    limiter, err := redisTemplate.NewTokenBucketLimiter(50, 100)
+    router.Use(specialdb.RateLimitMiddleware(specialdb.RateLimitConfig{
+        Limiter: limiter,
+        KeyBy:   specialdb.RateLimitByTenant,
+        PerRoute: true,
+    }))
+*/
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soedev/soelib/net/app"
+)
+
+// 限流维度
+const (
+	RateLimitByTenant = "tenant" // 租户中间件识别的租户号（未识别时取请求头 tenantId）
+	RateLimitByShop   = "shop"   // 请求头 shopCode
+	RateLimitByIP     = "ip"     // 客户端 IP
+)
+
+// RateLimitConfig 限流中间件配置
+type RateLimitConfig struct {
+	Limiter     Limiter
+	ServiceName string                      // 服务名称，同时作为限流键前缀
+	KeyBy       string                      // 限流维度，默认按 IP
+	KeyFunc     func(c *gin.Context) string // 自定义限流键，设置后忽略 KeyBy；返回空时不限流
+	PerRoute    bool                        // 是否按接口（方法 + 路由）分别限流
+	FailClosed  bool                        // redis 异常时拒绝请求，默认放行
+
+	// OnLimited 自定义限流响应（可选），默认返回 429
+	OnLimited func(c *gin.Context, result LimitResult)
+}
+
+// RateLimitMiddleware 分布式限流中间件，响应头返回 X-RateLimit-Limit、X-RateLimit-Remaining，被限流时返回 Retry-After（秒）
+func RateLimitMiddleware(config RateLimitConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := rateLimitKey(c, &config)
+		if key == "" {
+			c.Next()
+			return
+		}
+		result, err := config.Limiter.Allow(c.Request.Context(), key)
+		if err != nil {
+			fmt.Printf("------------RedisTemplate: RateLimit %s err:%v------------\n", key, err)
+			if config.FailClosed {
+				appG := app.Gin{C: c, ServiceName: config.ServiceName, Error: err}
+				appG.ResponseError(http.StatusServiceUnavailable, "限流服务不可用")
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		if result.Allowed {
+			c.Next()
+			return
+		}
+		if result.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(result.RetryAfter.Seconds())), 10))
+		}
+		if config.OnLimited != nil {
+			config.OnLimited(c, result)
+		} else {
+			appG := app.Gin{C: c, ServiceName: config.ServiceName}
+			appG.ResponseError(http.StatusTooManyRequests, "请求过于频繁，请稍后重试")
+		}
+		c.Abort()
+	}
+}
+
+// rateLimitKey 限流键：服务:路由:维度:值
+func rateLimitKey(c *gin.Context, config *RateLimitConfig) string {
+	var id string
+	if config.KeyFunc != nil {
+		id = config.KeyFunc(c)
+	} else {
+		switch config.KeyBy {
+		case RateLimitByTenant:
+			// 优先使用租户中间件识别的租户号，请求头由客户端控制，仅作兜底
+			id = c.GetString("tenantId")
+			if id == "" {
+				id = strings.TrimSpace(c.GetHeader("tenantId"))
+			}
+		case RateLimitByShop:
+			id = strings.TrimSpace(c.GetHeader("shopCode"))
+		default:
+			id = c.ClientIP()
+		}
+		if id != "" {
+			kind := config.KeyBy
+			if kind == "" {
+				kind = RateLimitByIP
+			}
+			id = kind + ":" + id
+		}
+	}
+	if id == "" {
+		return ""
+	}
+	parts := make([]string, 0, 3)
+	if config.ServiceName != "" {
+		parts = append(parts, config.ServiceName)
+	}
+	if config.PerRoute {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		parts = append(parts, c.Request.Method+" "+route)
+	}
+	return strings.Join(append(parts, id), ":")
+}
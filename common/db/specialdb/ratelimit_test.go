@@ -0,0 +1,163 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEvalLuaScript(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	script := `return redis.call("incr", KEYS[1])`
+
+	// 首次 NOSCRIPT 后改用 EVAL，之后命中缓存
+	for i := int64(1); i <= 2; i++ {
+		reply, err := s.EvalLuaScript(script, []string{"n"}, nil, ctx)
+		if err != nil || reply != i {
+			t.Fatalf("EvalLuaScript = %v, %v", reply, err)
+		}
+	}
+	mr.FlushAll()
+	sha, err := s.LoadScript(script, ctx)
+	if err != nil || sha != scriptSHA(script) {
+		t.Fatalf("LoadScript = %s, %v", sha, err)
+	}
+}
+
+func TestSlidingWindowLimiter(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	limiter, err := s.NewSlidingWindowLimiter(3, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "tenant:1")
+		if err != nil || !result.Allowed || result.Remaining != int64(2-i) {
+			t.Fatalf("第 %d 次 Allow = %+v, %v", i+1, result, err)
+		}
+	}
+	result, err := limiter.Allow(ctx, "tenant:1")
+	if err != nil || result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+		t.Fatalf("超限 Allow = %+v, %v", result, err)
+	}
+	if result, _ = limiter.Allow(ctx, "tenant:2"); !result.Allowed {
+		t.Fatal("不同键应独立计数")
+	}
+	if result, _ = limiter.AllowN(ctx, "tenant:3", 4); result.Allowed || result.RetryAfter != 0 {
+		t.Fatalf("请求数超过上限 = %+v", result)
+	}
+	// 以 redis 服务端时间计算窗口
+	mr.SetTime(time.Now().Add(1100 * time.Millisecond))
+	if result, _ = limiter.Allow(ctx, "tenant:1"); !result.Allowed {
+		t.Fatal("窗口过后应允许")
+	}
+	if _, err = s.NewSlidingWindowLimiter(0, time.Second); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("limit 为 0 err = %v", err)
+	}
+}
+
+func TestTokenBucketLimiter(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	limiter, err := s.NewTokenBucketLimiter(10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if result, err := limiter.Allow(ctx, "api"); err != nil || !result.Allowed {
+			t.Fatalf("突发 Allow = %+v, %v", result, err)
+		}
+	}
+	result, err := limiter.Allow(ctx, "api")
+	if err != nil || result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > 100*time.Millisecond {
+		t.Fatalf("令牌耗尽 Allow = %+v, %v", result, err)
+	}
+	mr.SetTime(time.Now().Add(150 * time.Millisecond))
+	if result, _ = limiter.Allow(ctx, "api"); !result.Allowed {
+		t.Fatal("补充令牌后应允许")
+	}
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		if _, err = s.NewTokenBucketLimiter(rate, 2); !errors.Is(err, ErrInvalidLimit) {
+			t.Fatalf("rate = %v err = %v", rate, err)
+		}
+	}
+	if _, err = s.NewTokenBucketLimiter(10, 0); !errors.Is(err, ErrInvalidLimit) {
+		t.Fatalf("burst 为 0 err = %v", err)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, _ := newTestTemplate(t)
+	limiter, err := s.NewSlidingWindowLimiter(1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(RateLimitMiddleware(RateLimitConfig{
+		Limiter:  limiter,
+		KeyBy:    RateLimitByTenant,
+		PerRoute: true,
+	}))
+	router.GET("/a", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/b", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(path, tenantID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("tenantId", tenantID)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("/a", "1001"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Fatalf("首次请求 %d %v", w.Code, w.Header())
+	}
+	w := do("/a", "1001")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("超限请求 %d Retry-After=%s", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w = do("/b", "1001"); w.Code != http.StatusOK {
+		t.Fatal("不同接口应分别限流")
+	}
+	if w = do("/a", "1002"); w.Code != http.StatusOK {
+		t.Fatal("不同租户应分别限流")
+	}
+}
+
+func TestRateLimitByTenantPrefersContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s, _ := newTestTemplate(t)
+	limiter, err := s.NewSlidingWindowLimiter(1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	// 模拟租户中间件：租户号来自登录信息，而非请求头
+	router.Use(func(c *gin.Context) { c.Set("tenantId", "1001") })
+	router.Use(RateLimitMiddleware(RateLimitConfig{Limiter: limiter, KeyBy: RateLimitByTenant}))
+	router.GET("/a", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(tenantID string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set("tenantId", tenantID)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := do("2001"); code != http.StatusOK {
+		t.Fatalf("首次请求 %d", code)
+	}
+	if code := do("2002"); code != http.StatusTooManyRequests {
+		t.Fatalf("更换请求头 tenantId 不应绕过租户限流, code = %d", code)
+	}
+}
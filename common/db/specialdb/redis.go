@@ -6,30 +6,52 @@ package specialdb
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/FZambia/sentinel"
 	"github.com/gin-gonic/gin"
 	"github.com/gomodule/redigo/redis"
+	"github.com/mna/redisc"
 	splunkredis "github.com/signalfx/splunk-otel-go/instrumentation/github.com/gomodule/redigo/splunkredigo/redis"
 	"github.com/soedev/soelib/common/des"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Redis 部署模式
+const (
+	RedisStandalone = "standalone" // 单机（默认）
+	RedisSentinel   = "sentinel"   // 哨兵
+	RedisCluster    = "cluster"    // 集群
+)
+
 // RedisConfig 连接配置
 type RedisConfig struct {
-	Host        string
-	Password    string
-	MaxIdle     int   //最大空闲连接数
-	MaxActive   int   //在给定时间内，允许分配的最大连接数（当为零时，没有限制）
-	IdleTimeout int64 //在给定时间内将会保持空闲状态，若到达时间限制则关闭连接（当为零时，没有限制）
-	Db          int   //设置redisDb
-	EnableTrace bool
+	Mode             string   // 部署模式：standalone（默认）、sentinel、cluster
+	Host             string   // 单机模式地址
+	MasterName       string   // 哨兵模式主节点名称
+	SentinelAddrs    []string // 哨兵模式哨兵地址列表
+	SentinelPassword string   // 哨兵密码（可选，DES 加密）
+	ClusterNodes     []string // 集群模式启动节点列表
+	Password         string
+	MaxIdle          int   //最大空闲连接数
+	MaxActive        int   //在给定时间内，允许分配的最大连接数（当为零时，没有限制）
+	IdleTimeout      int64 //在给定时间内将会保持空闲状态，若到达时间限制则关闭连接（当为零时，没有限制）
+	Db               int   //设置redisDb（集群模式不支持）
+	EnableTrace      bool
 }
 
 type RedisTemplate struct {
-	Pool        *redis.Pool
-	EnableTrace bool // 启用链路
+	Pool        *redis.Pool     // 单机、哨兵模式连接池（集群模式为 nil，直接取连接请使用 Conn）
+	Cluster     *redisc.Cluster // 集群模式
+	EnableTrace bool            // 启用链路
+	Namespace   string          // 键命名空间（租户、服务），见 WithNamespace
+	Codec       Codec           // GetAs/SetAs/MGet 使用的编解码，默认 JSONCodec
+	layout      *clusterLayout  // 集群槽位对应的节点（管道按节点分组）
 }
 
 // ConnRedis  设置redis 缓存
@@ -38,57 +60,224 @@ func ConnRedis(config RedisConfig) (*RedisTemplate, error) {
 	if config.Password != "" {
 		config.Password = des.DecryptDESECB([]byte(config.Password), des.DesKey)
 	}
-	pool := &redis.Pool{
-		MaxIdle:     config.MaxIdle,
-		MaxActive:   config.MaxActive,
-		IdleTimeout: time.Duration(config.IdleTimeout),
-		Dial: func() (redis.Conn, error) {
-			db := redis.DialDatabase(config.Db)
-			dbPwd := redis.DialPassword(config.Password)
-			if config.EnableTrace {
-				// 启用链路追踪
-				c, err := splunkredis.Dial("tcp", config.Host, db, dbPwd)
-				if err != nil {
-					return nil, err
-				}
-				return c, err
-			} else {
-				c, err := redis.Dial("tcp", config.Host, db, dbPwd)
-				if err != nil {
-					return nil, err
+	if config.SentinelPassword != "" {
+		config.SentinelPassword = des.DecryptDESECB([]byte(config.SentinelPassword), des.DesKey)
+	}
+	dial := func(address string, options ...redis.DialOption) (redis.Conn, error) {
+		if config.EnableTrace {
+			// 启用链路追踪
+			return splunkredis.Dial("tcp", address, options...)
+		}
+		return redis.Dial("tcp", address, options...)
+	}
+	newPool := func(dialFunc func() (redis.Conn, error)) *redis.Pool {
+		return &redis.Pool{
+			MaxIdle:     config.MaxIdle,
+			MaxActive:   config.MaxActive,
+			IdleTimeout: time.Duration(config.IdleTimeout),
+			Dial:        dialFunc,
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				if time.Since(t) < time.Minute*10 {
+					// 空闲大于10分钟才测试
+					return nil
 				}
-				return c, err
+				_, err := c.Do("PING")
+				return err
+			},
+		}
+	}
+	dbPwd := redis.DialPassword(config.Password)
+
+	switch config.Mode {
+	case "", RedisStandalone:
+		pool := newPool(func() (redis.Conn, error) {
+			return dial(config.Host, redis.DialDatabase(config.Db), dbPwd)
+		})
+		return &RedisTemplate{Pool: pool, EnableTrace: config.EnableTrace}, nil
+	case RedisSentinel:
+		if config.MasterName == "" || len(config.SentinelAddrs) == 0 {
+			return nil, errors.New("哨兵模式需要设置 MasterName 与 SentinelAddrs")
+		}
+		sntnl := &sentinel.Sentinel{
+			Addrs:      config.SentinelAddrs,
+			MasterName: config.MasterName,
+			Dial: func(addr string) (redis.Conn, error) {
+				// 哨兵连接必须设置超时
+				return redis.Dial("tcp", addr, redis.DialConnectTimeout(3*time.Second),
+					redis.DialReadTimeout(3*time.Second), redis.DialWriteTimeout(3*time.Second),
+					redis.DialPassword(config.SentinelPassword))
+			},
+		}
+		pool := newPool(func() (redis.Conn, error) {
+			masterAddr, err := sntnl.MasterAddr()
+			if err != nil {
+				return nil, err
 			}
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			if time.Since(t) < time.Minute*10 {
-				// 空闲大于10分钟才测试
+			conn, err := dial(masterAddr, redis.DialDatabase(config.Db), dbPwd)
+			if err != nil {
+				return nil, err
+			}
+			return &sentinelConn{Conn: conn}, nil
+		})
+		// 主从切换后旧主节点变为从节点：命令返回 READONLY 时连接池丢弃该连接（见 sentinelConn），
+		// 空闲超过 sentinelRoleCheckIdle 的连接借出时校验角色，活跃连接不额外增加往返
+		pool.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+			if time.Since(t) < sentinelRoleCheckIdle {
 				return nil
 			}
-			_, err := c.Do("PING")
-			return err
-		},
+			if !sentinel.TestRole(c, "master") {
+				return errNotMaster
+			}
+			return nil
+		}
+		return &RedisTemplate{Pool: pool, EnableTrace: config.EnableTrace}, nil
+	case RedisCluster:
+		if len(config.ClusterNodes) == 0 {
+			return nil, errors.New("集群模式需要设置 ClusterNodes")
+		}
+		cluster := &redisc.Cluster{
+			StartupNodes: config.ClusterNodes,
+			DialOptions:  []redis.DialOption{dbPwd, redis.DialConnectTimeout(5 * time.Second)},
+			CreatePool: func(address string, options ...redis.DialOption) (*redis.Pool, error) {
+				return newPool(func() (redis.Conn, error) {
+					return dial(address, options...)
+				}), nil
+			},
+		}
+		layout := &clusterLayout{}
+		cluster.LayoutRefresh = layout.update
+		// 加载槽位映射
+		if err := cluster.Refresh(); err != nil {
+			_ = cluster.Close()
+			return nil, errors.New("获取 redis 集群槽位失败:" + err.Error())
+		}
+		return &RedisTemplate{Cluster: cluster, EnableTrace: config.EnableTrace, layout: layout}, nil
+	default:
+		return nil, errors.New("不支持的 redis 部署模式：" + config.Mode)
+	}
+}
+
+// Conn 取得连接，用完需 Close；集群模式按 keys 绑定节点并自动处理重定向（Pool 在集群模式为 nil，外部代码应使用该方法）
+func (s *RedisTemplate) Conn(keys ...string) redis.Conn {
+	return s.getConn(keys...)
+}
+
+// sentinelRoleCheckIdle 哨兵模式下空闲超过该时间的连接借出时校验是否仍为主节点
+const sentinelRoleCheckIdle = time.Minute
+
+// errNotMaster 连接的节点已不是主节点（主从切换）
+var errNotMaster = errors.New("redis 节点已不是主节点")
+
+// sentinelConn 哨兵模式连接：命令返回 READONLY（旧主节点已变为从节点）时标记失效，归还后由连接池关闭，
+// 新连接通过哨兵重新获取主节点地址
+type sentinelConn struct {
+	redis.Conn
+	readOnly bool
+}
+
+func (c *sentinelConn) check(err error) {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) && strings.HasPrefix(string(redisErr), "READONLY") {
+		c.readOnly = true
+	}
+}
+
+func (c *sentinelConn) Err() error {
+	if c.readOnly {
+		return errNotMaster
+	}
+	return c.Conn.Err()
+}
+
+func (c *sentinelConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	reply, err := redis.ReceiveWithTimeout(c.Conn, timeout)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) DoContext(ctx context.Context, commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := redis.DoContext(c.Conn, ctx, commandName, args...)
+	c.check(err)
+	return reply, err
+}
+
+func (c *sentinelConn) ReceiveContext(ctx context.Context) (interface{}, error) {
+	reply, err := redis.ReceiveContext(c.Conn, ctx)
+	c.check(err)
+	return reply, err
+}
+
+// getConn 取得连接；集群模式按 keys 绑定节点（未指定时按命令的第一个参数路由），并自动处理 MOVED/ASK 重定向
+func (s *RedisTemplate) getConn(keys ...string) redis.Conn {
+	if s.Cluster == nil {
+		return s.Pool.Get()
+	}
+	conn := s.Cluster.Get()
+	if len(keys) > 0 {
+		if err := redisc.BindConn(conn, keys...); err != nil {
+			fmt.Printf("------------RedisTemplate: BindConn err:%v------------\n", err)
+		}
+	}
+	retryConn, err := redisc.RetryConn(conn, 3, 100*time.Millisecond)
+	if err != nil {
+		return conn
+	}
+	return retryConn
+}
+
+// eachNode 在每个主节点上执行（单机、哨兵模式仅一个节点），用于 KEYS 等节点级命令
+func (s *RedisTemplate) eachNode(fn func(conn redis.Conn) error) error {
+	if s.Cluster == nil {
+		conn := s.Pool.Get()
+		defer safeClose(conn, "eachNode")
+		return fn(conn)
+	}
+	return s.Cluster.EachNode(false, func(_ string, conn redis.Conn) error {
+		return fn(conn)
+	})
+}
+
+// Close 关闭连接池
+func (s *RedisTemplate) Close() error {
+	if s.Cluster != nil {
+		return s.Cluster.Close()
 	}
-	return &RedisTemplate{
-		Pool:        pool,
-		EnableTrace: config.EnableTrace,
-	}, nil
+	return s.Pool.Close()
 }
 
-// GetAndRenew 读取数据，当数据存在时自动续约过期时间(秒)
+// GetAndRenew 读取数据，当数据存在时自动续约过期时间(秒)；不存在时返回 ErrNotFound
 func (s *RedisTemplate) GetAndRenew(key string, exTime int, ctx context.Context) (value []byte, err error) {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "GetAndRenew")
 
 	reply, err := redis.Bytes(conn.Do("GET", s.buildArgs(ctx, []interface{}{key})...))
 	if err != nil {
-		fmt.Printf("------------RedisTemplate: GetAndRenew GET:err:%v------------\n", err)
-		return nil, err
+		return nil, wrapErr("GET", key, err)
 	}
 
 	_, err = conn.Do("EXPIRE", s.buildArgs(ctx, []interface{}{key, exTime})...)
 
 	if err != nil {
+		// 续约失败不影响读取结果
 		fmt.Printf("------------RedisTemplate: GetAndRenew EXPIRE:err:%v------------\n", err)
 	}
 	return reply, nil
@@ -96,19 +285,17 @@ func (s *RedisTemplate) GetAndRenew(key string, exTime int, ctx context.Context)
 
 // Renew  续期： key  续期时间（毫秒）
 func (s *RedisTemplate) Renew(key string, exTime int, ctx context.Context) error {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "Renew")
 	_, err := conn.Do("EXPIRE", s.buildArgs(ctx, []interface{}{key, exTime})...)
-	if err != nil {
-		fmt.Printf("------------RedisTemplate: Renew EXPIRE:err:%v------------\n", err)
-		return err
-	}
-	return nil
+	return wrapErr("EXPIRE", key, err)
 }
 
 // SetString 缓存数据：key 数据 过期时间（毫秒）
 func (s *RedisTemplate) SetString(key string, data string, times int, ctx context.Context) error {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "SetString")
 	var args []interface{}
 	if times == -1 {
@@ -118,17 +305,18 @@ func (s *RedisTemplate) SetString(key string, data string, times int, ctx contex
 	}
 	args = s.buildArgs(ctx, args)
 	_, err := conn.Do("SET", args...)
-	return err
+	return wrapErr("SET", key, err)
 }
 
 // Set 缓存数据：key 数据 过期时间（毫秒）
 func (s *RedisTemplate) Set(key string, data interface{}, time int, ctx context.Context) error {
-	conn := s.Pool.Get()
-	defer safeClose(conn, "Set")
+	key = s.Key(key)
 	value, err := json.Marshal(data)
 	if err != nil {
-		return err
+		return &RedisError{Op: "encode", Key: key, Err: err}
 	}
+	conn := s.getConn()
+	defer safeClose(conn, "Set")
 	var args []interface{}
 	if time == -1 {
 		args = []interface{}{key, value}
@@ -136,16 +324,13 @@ func (s *RedisTemplate) Set(key string, data interface{}, time int, ctx context.
 		args = []interface{}{key, value, "EX", time}
 	}
 	_, err = conn.Do("SET", s.buildArgs(ctx, args)...)
-	if err != nil {
-		fmt.Printf("------------RedisTemplate: Set err:%v------------\n", err)
-		return err
-	}
-	return nil
+	return wrapErr("SET", key, err)
 }
 
 // Exists 检测 key 是否存在
 func (s *RedisTemplate) Exists(key string, ctx context.Context) bool {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "Exists")
 	exists, err := redis.Bool(conn.Do("EXISTS", s.buildArgs(ctx, []interface{}{key})...))
 	if err != nil {
@@ -155,50 +340,40 @@ func (s *RedisTemplate) Exists(key string, ctx context.Context) bool {
 	return exists
 }
 
-// Get 读取缓存数据
+// Get 读取缓存数据，不存在时返回 ErrNotFound
 func (s *RedisTemplate) Get(key string, ctx context.Context) ([]byte, error) {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "Get")
 	reply, err := redis.Bytes(conn.Do("GET", s.buildArgs(ctx, []interface{}{key})...))
 	if err != nil {
-		if !errors.Is(err, redis.ErrNil) {
-			fmt.Printf("------------RedisTemplate: Get err:%v------------\n", err)
-		}
-		return nil, err
+		return nil, wrapErr("GET", key, err)
 	}
 	return reply, nil
 }
 
 // Delete 删除缓存数据
 func (s *RedisTemplate) Delete(key string, ctx context.Context) (bool, error) {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "Delete")
-	return redis.Bool(conn.Do("DEL", s.buildArgs(ctx, []interface{}{key})...))
+	deleted, err := redis.Bool(conn.Do("DEL", s.buildArgs(ctx, []interface{}{key})...))
+	return deleted, wrapErr("DEL", key, err)
 }
 
-// LikeDeletes  like 删除
+// LikeDeletes  like 删除（仅匹配当前命名空间下的键），使用 SCAN + UNLINK 分批删除，不阻塞 redis
 func (s *RedisTemplate) LikeDeletes(key string, ctx context.Context) error {
-	conn := s.Pool.Get()
-	defer safeClose(conn, "LikeDeletes")
-	keys, err := redis.Strings(conn.Do("KEYS", s.buildArgs(ctx, []interface{}{"*" + key + "*"})...))
-	if err != nil {
-		fmt.Printf("------------RedisTemplate: LikeDeletes KEYS:err:%v------------\n", err)
-		return err
-	}
-
-	for _, key := range keys {
-		_, err = s.Delete(key, ctx)
-		if err != nil {
-			fmt.Printf("------------RedisTemplate: LikeDeletes Delete:err:%v------------\n", err)
-			return err
-		}
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	return nil
+	_, err := s.DeletePattern(ctx, "*"+key+"*")
+	return err
 }
 
-// Lock 加锁
+// Lock 加锁（不续期、不可重入，长耗时任务请使用 NewLocker）
 func (s *RedisTemplate) Lock(lock, value string, expire int, ctx context.Context) (ok bool, err error) {
-	conn := s.Pool.Get()
+	lock = s.Key(lock)
+	conn := s.getConn()
 	defer safeClose(conn, "Lock")
 	//设置锁key-value和过期时间
 	_, err = redis.String(conn.Do("SET", s.buildArgs(ctx, []interface{}{lock, value, "EX", expire, "NX"})...))
@@ -206,7 +381,7 @@ func (s *RedisTemplate) Lock(lock, value string, expire int, ctx context.Context
 		if errors.Is(err, redis.ErrNil) {
 			return false, nil
 		}
-		return false, err
+		return false, &RedisError{Op: "SET NX", Key: lock, Err: err}
 	}
 	return true, nil
 }
@@ -222,7 +397,7 @@ func (s *RedisTemplate) Unlock(key, value string, ctx context.Context) error {
 	`
 	reply, err := s.EvalLuaScript(luaScript, []string{key}, []interface{}{value}, ctx)
 	if err != nil {
-		fmt.Printf("------------RedisTemplate: Unlock EVAL:err:%v------------\n", err)
+		return err
 	}
 	// 返回 0 表示未删除
 	deleted, _ := redis.Int(reply, nil)
@@ -264,43 +439,113 @@ func (s *RedisTemplate) Unlock(key, value string, ctx context.Context) error {
 
 // Incr 自增
 func (s *RedisTemplate) Incr(key string, ctx context.Context) (result int, err error) {
-	conn := s.Pool.Get()
+	key = s.Key(key)
+	conn := s.getConn()
 	defer safeClose(conn, "Incr")
 
 	result, err = redis.Int(conn.Do("INCR", s.buildArgs(ctx, []interface{}{key})...))
-	if err != nil {
-		fmt.Printf("------------RedisTemplate: Incr INCR:err:%v------------\n", err)
-		return result, err
-	}
-	return result, nil
+	return result, wrapErr("INCR", key, err)
 }
 
 // HasGetAll 获取所有数据：key 数据 过期时间（毫秒）
+// Deprecated: 不返回字段名，请使用 HGetAll
 func (s *RedisTemplate) HasGetAll(hasKey string, ctx context.Context) ([][]byte, error) {
-	conn := s.Pool.Get()
+	hasKey = s.Key(hasKey)
+	conn := s.getConn()
 	defer safeClose(conn, "HasGetAll")
 	reply, err := redis.Values(conn.Do("HGETALL", s.buildArgs(ctx, []interface{}{hasKey})...))
 	if err != nil {
-		return nil, err
+		return nil, wrapErr("HGETALL", hasKey, err)
 	}
 	m, _ := redisHGETALLToMap(reply)
 	return m, nil
 }
 
-// EvalLuaScript 通用 Lua 脚本执行器
+// EvalLuaScript 通用 Lua 脚本执行器（keys 自动加上命名空间）
+// 优先以 EVALSHA 执行已缓存的脚本，服务端未缓存（NOSCRIPT）时改用 EVAL，执行的同时缓存脚本
 func (s *RedisTemplate) EvalLuaScript(script string, keys []string, args []interface{}, ctx context.Context) (interface{}, error) {
-	conn := s.Pool.Get()
+	fullKeys := make([]string, len(keys))
+	for i, k := range keys {
+		fullKeys[i] = s.Key(k)
+	}
+	conn := s.getConn(fullKeys...)
 	defer safeClose(conn, "EvalLuaScript")
 	// 将 keys 和 args 打平到 []interface{}
 	redisArgs := make([]interface{}, 0, 2+len(keys)+len(args))
-	redisArgs = append(redisArgs, script)
-	redisArgs = append(redisArgs, len(keys))
-	for _, k := range keys {
+	redisArgs = append(redisArgs, scriptSHA(script))
+	redisArgs = append(redisArgs, len(fullKeys))
+	for _, k := range fullKeys {
 		redisArgs = append(redisArgs, k)
 	}
 	redisArgs = append(redisArgs, args...)
 	redisArgs = s.buildArgs(ctx, redisArgs)
-	return conn.Do("EVAL", redisArgs...)
+	reply, err := conn.Do("EVALSHA", redisArgs...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		redisArgs[0] = script
+		reply, err = conn.Do("EVAL", redisArgs...)
+	}
+	if err != nil {
+		return nil, &RedisError{Op: "EVAL", Key: strings.Join(fullKeys, ","), Err: err}
+	}
+	return reply, nil
+}
+
+// LoadScript 在所有节点上预加载脚本（SCRIPT LOAD），返回脚本 SHA1
+func (s *RedisTemplate) LoadScript(script string, ctx context.Context) (string, error) {
+	err := s.eachNode(func(conn redis.Conn) error {
+		_, err := conn.Do("SCRIPT", s.buildArgs(ctx, []interface{}{"LOAD", script})...)
+		return err
+	})
+	if err != nil {
+		return "", &RedisError{Op: "SCRIPT LOAD", Err: err}
+	}
+	return scriptSHA(script), nil
+}
+
+// luaNow 脚本开头取得 redis 服务端当前时间（毫秒）存入 now，多副本共用同一时钟，不受各自时钟偏差影响
+// 写命令前调用 TIME 需要按命令复制（redis 5 起为默认行为，此处兼容更早版本）
+const luaNow = `
+	redis.replicate_commands()
+	local time = redis.call("TIME")
+	local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+`
+
+// scriptSHAs 脚本 -> SHA1 缓存
+var scriptSHAs sync.Map
+
+func scriptSHA(script string) string {
+	if sha, ok := scriptSHAs.Load(script); ok {
+		return sha.(string)
+	}
+	sum := sha1.Sum([]byte(script))
+	sha := hex.EncodeToString(sum[:])
+	scriptSHAs.Store(script, sha)
+	return sha
+}
+
+// doAs 执行单键命令并转换结果（key 自动加上命名空间），nil 结果返回 ErrNotFound
+func doAs[T any](s *RedisTemplate, convert func(interface{}, error) (T, error), cmd, key string, args []interface{}, ctx context.Context) (T, error) {
+	key = s.Key(key)
+	conn := s.getConn()
+	defer safeClose(conn, cmd)
+	value, err := convert(conn.Do(cmd, s.buildArgs(ctx, append([]interface{}{key}, args...))...))
+	return value, wrapErr(cmd, key, err)
+}
+
+// byteMap 将 [field, value, ...] 结果转为 map
+func byteMap(reply interface{}, err error) (map[string][]byte, error) {
+	values, err := redis.ByteSlices(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(values)%2 != 0 {
+		return nil, errors.New("redis: 键值对结果数量不是偶数")
+	}
+	result := make(map[string][]byte, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		result[string(values[i])] = values[i+1]
+	}
+	return result, nil
 }
 
 func (s *RedisTemplate) buildArgs(ctx context.Context, args []interface{}) []interface{} {
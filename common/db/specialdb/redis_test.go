@@ -0,0 +1,153 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gomodule/redigo/redis"
+)
+
+// newTestTemplate 基于 miniredis 的 RedisTemplate
+func newTestTemplate(t *testing.T) (*RedisTemplate, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	pool := &redis.Pool{
+		MaxIdle: 5,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", mr.Addr())
+		},
+	}
+	t.Cleanup(func() { _ = pool.Close() })
+	return &RedisTemplate{Pool: pool}, mr
+}
+
+func TestRedisTemplate(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	ctx := context.Background()
+	cache := s.WithNamespace("crm", "1001")
+
+	if _, err := cache.Get("member", ctx); !errors.Is(err, ErrNotFound) || !errors.Is(err, redis.ErrNil) {
+		t.Fatalf("Get 不存在的键 err = %v", err)
+	}
+	if err := SetAs(ctx, cache, "member", codecItem{ID: 1, Name: "张三"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !mr.Exists("crm:1001:member") {
+		t.Fatal("键未加上命名空间")
+	}
+	item, err := GetAs[codecItem](ctx, cache, "member")
+	if err != nil || item.Name != "张三" {
+		t.Fatalf("GetAs = %v, %v", item, err)
+	}
+	if _, err = GetAs[codecItem](ctx, s, "member"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("不同命名空间 GetAs err = %v", err)
+	}
+
+	_ = SetAs(ctx, cache, "member2", codecItem{ID: 2}, 0)
+	items, err := MGet[codecItem](ctx, cache, "member", "member2", "member3")
+	if err != nil || len(items) != 2 || items["member2"].ID != 2 {
+		t.Fatalf("MGet = %v, %v", items, err)
+	}
+
+	_ = s.SetString("member", "other", -1, ctx)
+	if err = cache.LikeDeletes("member", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if mr.Exists("crm:1001:member") || !mr.Exists("member") {
+		t.Fatal("LikeDeletes 应只删除当前命名空间下的键")
+	}
+
+	ok, err := cache.Lock("lock", "a", 10, ctx)
+	if !ok || err != nil {
+		t.Fatalf("Lock = %v, %v", ok, err)
+	}
+	if err = cache.Unlock("lock", "b", ctx); err == nil {
+		t.Fatal("其他持有者不应释放锁")
+	}
+	if err = cache.Unlock("lock", "a", ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnRedisSentinel(t *testing.T) {
+	master := miniredis.RunT(t)
+	// miniredis 不支持哨兵命令，注册 SENTINEL 返回主节点地址
+	sntnl := miniredis.RunT(t)
+	if err := sntnl.Server().Register("SENTINEL", func(c *server.Peer, _ string, _ []string) {
+		c.WriteStrings([]string{master.Host(), master.Port()})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ConnRedis(RedisConfig{Mode: RedisSentinel, MasterName: "mymaster", SentinelAddrs: []string{sntnl.Addr()}, MaxIdle: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err = s.SetString("k", "v", -1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pool.IdleCount() != 1 {
+		t.Fatalf("连接应归还连接池，空闲连接数 = %d", s.Pool.IdleCount())
+	}
+	// 主从切换后旧主节点返回 READONLY，连接不再归还连接池
+	master.SetError("READONLY You can't write against a read only replica.")
+	if err = s.SetString("k", "v", -1, ctx); err == nil {
+		t.Fatal("期望返回 READONLY 错误")
+	}
+	if s.Pool.IdleCount() != 0 {
+		t.Fatalf("READONLY 连接应被丢弃，空闲连接数 = %d", s.Pool.IdleCount())
+	}
+	master.SetError("")
+	if value, err := s.Get("k", ctx); err != nil || string(value) != "v" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+}
+
+func TestConnRedisCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s, err := ConnRedis(RedisConfig{Mode: RedisCluster, ClusterNodes: []string{mr.Addr()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if s.Pool != nil {
+		t.Fatal("集群模式 Pool 应为 nil")
+	}
+	if err = s.SetString("k", "v", -1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	conn := s.Conn("k")
+	defer conn.Close()
+	if value, err := redis.String(conn.Do("GET", "k")); err != nil || value != "v" {
+		t.Fatalf("GET = %q, %v", value, err)
+	}
+}
+
+func TestConnRedisTrace(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s, err := ConnRedis(RedisConfig{Host: mr.Addr(), MaxIdle: 2, EnableTrace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	// 启用链路时 ctx 作为最后一个参数传给连接，不能作为命令参数发送
+	if err = s.SetString("k", "v", -1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := mr.Get("k"); err != nil || value != "v" {
+		t.Fatalf("miniredis Get = %q, %v", value, err)
+	}
+	if value, err := s.Get("k", ctx); err != nil || string(value) != "v" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+}
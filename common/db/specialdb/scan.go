@@ -0,0 +1,158 @@
+package specialdb
+
+/**
+  基于 SCAN 游标的键遍历与模式删除，分批处理，不阻塞 redis（替代 KEYS）
+    err := redisTemplate.ScanKeys(ctx, "member:*", func(keys []string) error { ... })
+    deleted, err := redisTemplate.DeletePattern(ctx, "member:*", specialdb.ScanProgress(func(p specialdb.DeleteProgress) { ... }))
+*/
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type scanOptions struct {
+	count    int
+	keyType  string
+	progress func(DeleteProgress)
+}
+
+// ScanOption ScanKeys、DeletePattern 参数
+type ScanOption func(*scanOptions)
+
+// ScanCount 每次 SCAN 的 COUNT 提示值，默认 500
+func ScanCount(count int) ScanOption {
+	return func(o *scanOptions) {
+		o.count = count
+	}
+}
+
+// ScanType 只遍历指定类型的键（string、hash、list、set、zset、stream），需 redis 6.0+
+func ScanType(keyType string) ScanOption {
+	return func(o *scanOptions) {
+		o.keyType = keyType
+	}
+}
+
+// ScanProgress DeletePattern 每删除一批后回调
+func ScanProgress(fn func(DeleteProgress)) ScanOption {
+	return func(o *scanOptions) {
+		o.progress = fn
+	}
+}
+
+// DeleteProgress 模式删除进度
+type DeleteProgress struct {
+	Scanned int64 // 已遍历的键数
+	Deleted int64 // 已删除的键数
+}
+
+func newScanOptions(opts []ScanOption) *scanOptions {
+	opt := &scanOptions{count: 500}
+	for _, o := range opts {
+		o(opt)
+	}
+	if opt.count <= 0 {
+		opt.count = 500
+	}
+	return opt
+}
+
+// ScanKeys 遍历当前命名空间下匹配 match 的键（不含命名空间前缀），每批调用一次 fn；fn 返回错误或 ctx 取消时停止
+// 集群模式依次遍历每个主节点；遍历期间新增或删除的键可能被遗漏或重复返回（SCAN 语义）
+func (s *RedisTemplate) ScanKeys(ctx context.Context, match string, fn func(keys []string) error, opts ...ScanOption) error {
+	prefix := s.Key("")
+	return s.scanRaw(ctx, s.Key(match), newScanOptions(opts), func(_ redis.Conn, keys []string) error {
+		if prefix != "" {
+			for i, key := range keys {
+				keys[i] = strings.TrimPrefix(key, prefix)
+			}
+		}
+		return fn(keys)
+	})
+}
+
+// DeletePattern 删除当前命名空间下匹配 match 的键，按批使用 UNLINK 管道删除（redis 4.0 以下自动改用 DEL）
+func (s *RedisTemplate) DeletePattern(ctx context.Context, match string, opts ...ScanOption) (int64, error) {
+	opt := newScanOptions(opts)
+	var progress DeleteProgress
+	unlink := true
+	err := s.scanRaw(ctx, s.Key(match), opt, func(conn redis.Conn, keys []string) error {
+		progress.Scanned += int64(len(keys))
+		deleted, err := deleteKeys(conn, keys, &unlink)
+		progress.Deleted += deleted
+		if opt.progress != nil {
+			opt.progress(progress)
+		}
+		return err
+	})
+	return progress.Deleted, err
+}
+
+// scanRaw 在每个节点上按游标遍历完整键，fn 收到的 conn 与键位于同一节点
+func (s *RedisTemplate) scanRaw(ctx context.Context, pattern string, opt *scanOptions, fn func(conn redis.Conn, keys []string) error) error {
+	return s.eachNode(func(conn redis.Conn) error {
+		cursor := "0"
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			args := []interface{}{cursor, "MATCH", pattern, "COUNT", opt.count}
+			if opt.keyType != "" {
+				args = append(args, "TYPE", opt.keyType)
+			}
+			values, err := redis.Values(conn.Do("SCAN", s.buildArgs(ctx, args)...))
+			if err != nil {
+				return &RedisError{Op: "SCAN", Key: pattern, Err: err}
+			}
+			var keys []string
+			if _, err = redis.Scan(values, &cursor, &keys); err != nil {
+				return &RedisError{Op: "SCAN", Key: pattern, Err: err}
+			}
+			if len(keys) > 0 {
+				if err = fn(conn, keys); err != nil {
+					return err
+				}
+			}
+			if cursor == "0" {
+				return nil
+			}
+		}
+	})
+}
+
+// deleteKeys 以管道逐个删除键（集群模式下同一节点的键也可能位于不同槽位，不能合并为一条命令）
+func deleteKeys(conn redis.Conn, keys []string, unlink *bool) (int64, error) {
+	cmd := "DEL"
+	if *unlink {
+		cmd = "UNLINK"
+	}
+	for _, key := range keys {
+		if err := conn.Send(cmd, key); err != nil {
+			return 0, &RedisError{Op: cmd, Key: key, Err: err}
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, &RedisError{Op: cmd, Key: keys[0], Err: err}
+	}
+	var deleted int64
+	var firstErr error
+	for _, key := range keys {
+		n, err := redis.Int64(conn.Receive())
+		if err != nil {
+			if firstErr == nil {
+				firstErr = &RedisError{Op: cmd, Key: key, Err: err}
+			}
+			continue
+		}
+		deleted += n
+	}
+	if firstErr != nil && *unlink && strings.Contains(strings.ToLower(firstErr.Error()), "unknown command") {
+		*unlink = false
+		n, err := deleteKeys(conn, keys, unlink)
+		return deleted + n, err
+	}
+	return deleted, firstErr
+}
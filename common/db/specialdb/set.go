@@ -0,0 +1,82 @@
+package specialdb
+
+/**
+  集合操作
+*/
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// SAdd 添加成员，返回新增的成员数
+func (s *RedisTemplate) SAdd(key string, members []interface{}, ctx context.Context) (int64, error) {
+	args, err := s.encodeValues(key, members)
+	if err != nil {
+		return 0, err
+	}
+	return doAs(s, redis.Int64, "SADD", key, args, ctx)
+}
+
+// SRem 删除成员，返回删除的成员数
+func (s *RedisTemplate) SRem(key string, members []interface{}, ctx context.Context) (int64, error) {
+	args, err := s.encodeValues(key, members)
+	if err != nil {
+		return 0, err
+	}
+	return doAs(s, redis.Int64, "SREM", key, args, ctx)
+}
+
+// SMembers 读取全部成员（大集合请使用 SScan）
+func (s *RedisTemplate) SMembers(key string, ctx context.Context) ([]string, error) {
+	return doAs(s, redis.Strings, "SMEMBERS", key, nil, ctx)
+}
+
+// SIsMember 是否为集合成员
+func (s *RedisTemplate) SIsMember(key string, member interface{}, ctx context.Context) (bool, error) {
+	data, err := s.encode(member)
+	if err != nil {
+		return false, &RedisError{Op: "encode", Key: s.Key(key), Err: err}
+	}
+	return doAs(s, redis.Bool, "SISMEMBER", key, []interface{}{data}, ctx)
+}
+
+// SCard 成员数
+func (s *RedisTemplate) SCard(key string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "SCARD", key, nil, ctx)
+}
+
+// SPop 随机弹出一个成员，集合为空时返回 ErrNotFound
+func (s *RedisTemplate) SPop(key string, ctx context.Context) (string, error) {
+	return doAs(s, redis.String, "SPOP", key, nil, ctx)
+}
+
+// SScan 按游标遍历集合成员，每批调用一次 fn
+func (s *RedisTemplate) SScan(key, match string, fn func(members []string) error, ctx context.Context) error {
+	cursor := "0"
+	if match == "" {
+		match = "*"
+	}
+	for {
+		if ctx != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		values, err := doAs(s, redis.Values, "SSCAN", key, []interface{}{cursor, "MATCH", match, "COUNT", 500}, ctx)
+		if err != nil {
+			return err
+		}
+		var members []string
+		if _, err = redis.Scan(values, &cursor, &members); err != nil {
+			return &RedisError{Op: "SSCAN", Key: s.Key(key), Err: err}
+		}
+		if len(members) > 0 {
+			if err = fn(members); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
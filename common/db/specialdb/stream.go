@@ -0,0 +1,370 @@
+package specialdb
+
+/**
+  Redis Streams 消费组：XREADGROUP 读取、处理成功后 XACK；处理失败或消费者宕机的消息超过 ClaimIdle 后由 XAUTOCLAIM 重新认领
+  投递次数超过 MaxDeliveries 的消息转入死信流并确认，避免无限重试
+    id, err := redisTemplate.XAdd(ctx, "member:events", map[string]interface{}{"type": "register", "id": memberID}, 100000)
+    err := redisTemplate.RunStreamWorker(ctx, specialdb.StreamWorkerConfig{Stream: "member:events", Group: "crm"},
+        func(ctx context.Context, msg specialdb.StreamMessage) error { ... })
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// StreamMessage 流消息
+type StreamMessage struct {
+	Stream     string // 流（不含命名空间）
+	ID         string
+	Values     map[string][]byte
+	Deliveries int64 // 投递次数，首次投递为 1
+}
+
+// StreamWorkerConfig 消费组配置
+type StreamWorkerConfig struct {
+	Stream        string        // 流（自动加上命名空间）
+	Group         string        // 消费组，不存在时自动创建（同时创建流）
+	Consumer      string        // 消费者名称，默认 主机名-进程号
+	StartID       string        // 创建消费组时的起始 ID，默认 "$" 只消费之后的新消息，"0" 消费全部历史消息
+	Concurrency   int           // 并发处理数，默认1
+	BatchSize     int           // 每次读取数量，默认10
+	Block         time.Duration // 无消息时阻塞等待时间，默认5秒（停止时最多延迟该时间）
+	ClaimIdle     time.Duration // 消息未确认超过该时间后被重新认领，默认1分钟
+	ClaimInterval time.Duration // 检查待认领消息的间隔，默认30秒
+	MaxDeliveries int64         // 最大投递次数，超过后转入死信流，默认5
+	DeadLetter    string        // 死信流，默认 Stream + ":dead"
+
+	// OnDeadLetter 转入死信流后回调（可选）
+	OnDeadLetter func(ctx context.Context, msg StreamMessage)
+}
+
+func (c *StreamWorkerConfig) setDefaults() {
+	if c.Consumer == "" {
+		host, _ := os.Hostname()
+		c.Consumer = host + "-" + strconv.Itoa(os.Getpid())
+	}
+	if c.StartID == "" {
+		c.StartID = "$"
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 10
+	}
+	if c.Block <= 0 {
+		c.Block = 5 * time.Second
+	}
+	if c.ClaimIdle <= 0 {
+		c.ClaimIdle = time.Minute
+	}
+	if c.ClaimInterval <= 0 {
+		c.ClaimInterval = 30 * time.Second
+	}
+	if c.MaxDeliveries <= 0 {
+		c.MaxDeliveries = 5
+	}
+	if c.DeadLetter == "" {
+		c.DeadLetter = c.Stream + ":dead"
+	}
+}
+
+// XAdd 追加消息，返回消息 ID；maxLen>0 时近似裁剪流长度；值为 []byte、string 时原样写入，其他类型按 Codec 编码
+func (s *RedisTemplate) XAdd(ctx context.Context, stream string, values map[string]interface{}, maxLen int64) (string, error) {
+	args := make([]interface{}, 0, len(values)*2+4)
+	if maxLen > 0 {
+		args = append(args, "MAXLEN", "~", maxLen)
+	}
+	args = append(args, "*")
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		data, err := s.encode(values[field])
+		if err != nil {
+			return "", &RedisError{Op: "encode", Key: s.Key(stream), Err: err}
+		}
+		args = append(args, field, data)
+	}
+	return doAs(s, redis.String, "XADD", stream, args, ctx)
+}
+
+// XLen 流长度
+func (s *RedisTemplate) XLen(ctx context.Context, stream string) (int64, error) {
+	return doAs(s, redis.Int64, "XLEN", stream, nil, ctx)
+}
+
+// RunStreamWorker 以消费组方式处理流消息，阻塞直到 ctx 结束；handler 返回错误时消息保持未确认，等待重新认领后重试
+func (s *RedisTemplate) RunStreamWorker(ctx context.Context, config StreamWorkerConfig, handler func(ctx context.Context, msg StreamMessage) error) error {
+	if config.Stream == "" || config.Group == "" {
+		return errors.New("redis: Stream 与 Group 不能为空")
+	}
+	config.setDefaults()
+	w := &streamWorker{s: s, config: config, stream: s.Key(config.Stream), handler: handler}
+	if err := w.createGroup(ctx); err != nil {
+		return err
+	}
+
+	jobs := make(chan StreamMessage)
+	var wg sync.WaitGroup
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				w.process(ctx, msg)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	dispatch := func(msgs []StreamMessage) error {
+		for _, msg := range msgs {
+			select {
+			case jobs <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	// 启动时先认领本消费者或其他宕机消费者遗留的消息
+	var lastClaim time.Time
+	backoff := time.Second
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= config.ClaimInterval {
+			lastClaim = time.Now()
+			msgs, err := w.claim(ctx)
+			if err != nil {
+				fmt.Printf("------------RedisTemplate: StreamWorker %s XAUTOCLAIM err:%v------------\n", w.stream, err)
+			} else if err = dispatch(msgs); err != nil {
+				break
+			}
+		}
+		msgs, err := w.read(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			fmt.Printf("------------RedisTemplate: StreamWorker %s XREADGROUP err:%v，%v 后重试------------\n", w.stream, err, backoff)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+		if err = dispatch(msgs); err != nil {
+			break
+		}
+	}
+	return ctx.Err()
+}
+
+type streamWorker struct {
+	s       *RedisTemplate
+	config  StreamWorkerConfig
+	stream  string // 完整键
+	handler func(ctx context.Context, msg StreamMessage) error
+}
+
+func (w *streamWorker) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn := w.s.getConn(w.stream)
+	defer safeClose(conn, cmd)
+	reply, err := conn.Do(cmd, w.s.buildArgs(ctx, args)...)
+	if err != nil {
+		return nil, wrapErr(cmd, w.stream, err)
+	}
+	return reply, nil
+}
+
+// createGroup 创建消费组，已存在时忽略
+func (w *streamWorker) createGroup(ctx context.Context) error {
+	_, err := w.do(ctx, "XGROUP", "CREATE", w.stream, w.config.Group, w.config.StartID, "MKSTREAM")
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// read 读取新消息，超时无消息时返回空
+func (w *streamWorker) read(ctx context.Context) ([]StreamMessage, error) {
+	reply, err := w.do(ctx, "XREADGROUP", "GROUP", w.config.Group, w.config.Consumer,
+		"COUNT", w.config.BatchSize, "BLOCK", w.config.Block.Milliseconds(), "STREAMS", w.stream, ">")
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+	var msgs []StreamMessage
+	for _, stream := range streams {
+		pair, err := redis.Values(stream, nil)
+		if err != nil || len(pair) != 2 {
+			return nil, errors.New("redis: 无法识别的 XREADGROUP 返回结果")
+		}
+		entries, _, err := w.parseEntries(pair[1])
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entries[i].Deliveries = 1
+		}
+		msgs = append(msgs, entries...)
+	}
+	return msgs, nil
+}
+
+// claim 认领空闲超过 ClaimIdle 的消息，投递次数超限的转入死信流
+func (w *streamWorker) claim(ctx context.Context) ([]StreamMessage, error) {
+	var result []StreamMessage
+	cursor := "0-0"
+	for {
+		reply, err := redis.Values(w.do(ctx, "XAUTOCLAIM", w.stream, w.config.Group, w.config.Consumer,
+			w.config.ClaimIdle.Milliseconds(), cursor, "COUNT", w.config.BatchSize))
+		if err != nil {
+			return result, err
+		}
+		if len(reply) < 2 {
+			return result, errors.New("redis: 无法识别的 XAUTOCLAIM 返回结果")
+		}
+		cursor, _ = redis.String(reply[0], nil)
+		msgs, deleted, err := w.parseEntries(reply[1])
+		if err != nil {
+			return result, err
+		}
+		// redis 7.0 起第三项为已被删除（裁剪）的消息 ID
+		if len(reply) > 2 {
+			ids, _ := redis.Strings(reply[2], nil)
+			deleted = append(deleted, ids...)
+		}
+		if len(deleted) > 0 {
+			_ = w.ack(ctx, deleted...)
+		}
+		if err = w.fillDeliveries(ctx, msgs); err != nil {
+			return result, err
+		}
+		for _, msg := range msgs {
+			if msg.Deliveries > w.config.MaxDeliveries {
+				w.deadLetter(ctx, msg)
+				continue
+			}
+			result = append(result, msg)
+		}
+		if cursor == "0-0" || cursor == "" {
+			return result, nil
+		}
+	}
+}
+
+// fillDeliveries 查询消息的投递次数
+func (w *streamWorker) fillDeliveries(ctx context.Context, msgs []StreamMessage) error {
+	for i := range msgs {
+		reply, err := redis.Values(w.do(ctx, "XPENDING", w.stream, w.config.Group, msgs[i].ID, msgs[i].ID, 1))
+		if err != nil {
+			return err
+		}
+		msgs[i].Deliveries = 1
+		if len(reply) > 0 {
+			// [id, consumer, idle, deliveries]
+			if info, err := redis.Values(reply[0], nil); err == nil && len(info) == 4 {
+				msgs[i].Deliveries, _ = redis.Int64(info[3], nil)
+			}
+		}
+	}
+	return nil
+}
+
+// parseEntries 解析 [[id, [field, value, ...]], ...]，消息内容为空（已删除）的 ID 单独返回
+func (w *streamWorker) parseEntries(reply interface{}) (msgs []StreamMessage, deleted []string, err error) {
+	entries, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		parts, err := redis.Values(entry, nil)
+		if err != nil || len(parts) != 2 {
+			return nil, nil, errors.New("redis: 无法识别的流消息")
+		}
+		id, _ := redis.String(parts[0], nil)
+		if parts[1] == nil {
+			deleted = append(deleted, id)
+			continue
+		}
+		values, err := byteMap(parts[1], nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs = append(msgs, StreamMessage{Stream: w.config.Stream, ID: id, Values: values})
+	}
+	return msgs, deleted, nil
+}
+
+func (w *streamWorker) ack(ctx context.Context, ids ...string) error {
+	args := []interface{}{w.stream, w.config.Group}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := w.do(ctx, "XACK", args...)
+	return err
+}
+
+// process 处理消息，成功后确认
+func (w *streamWorker) process(ctx context.Context, msg StreamMessage) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("处理消息异常: %v", r)
+			}
+		}()
+		return w.handler(ctx, msg)
+	}()
+	if err != nil {
+		fmt.Printf("------------RedisTemplate: StreamWorker %s 消息 %s 第 %d 次处理失败:%v------------\n", w.stream, msg.ID, msg.Deliveries, err)
+		return
+	}
+	if err = w.ack(context.WithoutCancel(ctx), msg.ID); err != nil {
+		fmt.Printf("------------RedisTemplate: StreamWorker %s XACK %s err:%v------------\n", w.stream, msg.ID, err)
+	}
+}
+
+// deadLetter 写入死信流（附带原消息 ID 与投递次数）并确认原消息
+func (w *streamWorker) deadLetter(ctx context.Context, msg StreamMessage) {
+	values := make(map[string]interface{}, len(msg.Values)+3)
+	for field, value := range msg.Values {
+		values[field] = value
+	}
+	values["x-origin-stream"] = msg.Stream
+	values["x-origin-id"] = msg.ID
+	values["x-deliveries"] = strconv.FormatInt(msg.Deliveries, 10)
+	if _, err := w.s.XAdd(ctx, w.config.DeadLetter, values, 0); err != nil {
+		fmt.Printf("------------RedisTemplate: StreamWorker %s 写入死信流 err:%v------------\n", w.stream, err)
+		return
+	}
+	if err := w.ack(ctx, msg.ID); err != nil {
+		fmt.Printf("------------RedisTemplate: StreamWorker %s XACK %s err:%v------------\n", w.stream, msg.ID, err)
+	}
+	if w.config.OnDeadLetter != nil {
+		w.config.OnDeadLetter(ctx, msg)
+	}
+}
@@ -0,0 +1,123 @@
+package specialdb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestSubscribe(t *testing.T) {
+	s, mr := newTestTemplate(t)
+	cache := s.WithNamespace("crm")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- cache.Subscribe(ctx, []string{"reload"}, func(ctx context.Context, msg Message) {
+			received <- msg
+		})
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.PubSubNumSub("crm:reload")["crm:reload"] == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n, err := cache.Publish(ctx, "reload", "1001"); err != nil || n != 1 {
+		t.Fatalf("Publish = %d, %v", n, err)
+	}
+	select {
+	case msg := <-received:
+		if msg.Channel != "reload" || string(msg.Data) != "1001" {
+			t.Fatalf("收到消息 %+v", msg)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到消息")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Subscribe 返回 %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ctx 取消后未退出")
+	}
+}
+
+func TestStreamWorker(t *testing.T) {
+	s, _ := newTestTemplate(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := StreamWorkerConfig{
+		Stream:        "events",
+		Group:         "crm",
+		StartID:       "0",
+		Concurrency:   2,
+		Block:         20 * time.Millisecond,
+		ClaimIdle:     30 * time.Millisecond,
+		ClaimInterval: 30 * time.Millisecond,
+		MaxDeliveries: 2,
+	}
+	for _, v := range []string{"a", "bad", "c"} {
+		if _, err := s.XAdd(ctx, "events", map[string]interface{}{"v": v}, 1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	handled := map[string]int{}
+	dead := make(chan StreamMessage, 1)
+	config.OnDeadLetter = func(ctx context.Context, msg StreamMessage) {
+		dead <- msg
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.RunStreamWorker(ctx, config, func(ctx context.Context, msg StreamMessage) error {
+			v := string(msg.Values["v"])
+			mu.Lock()
+			handled[v]++
+			mu.Unlock()
+			if v == "bad" {
+				return errors.New("处理失败")
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case msg := <-dead:
+		if string(msg.Values["v"]) != "bad" || msg.Deliveries <= 2 {
+			t.Fatalf("死信消息 %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("失败消息未转入死信流")
+	}
+	mu.Lock()
+	if handled["a"] != 1 || handled["c"] != 1 || handled["bad"] != 2 {
+		t.Fatalf("处理次数 %v", handled)
+	}
+	mu.Unlock()
+	if n, _ := s.XLen(ctx, "events:dead"); n != 1 {
+		t.Fatalf("死信流长度 %d", n)
+	}
+	pending, err := doAs(s, redis.Values, "XPENDING", "events", []interface{}{"crm"}, ctx)
+	if err != nil || pending[0] != int64(0) {
+		t.Fatalf("未确认消息 %v, %v", pending, err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("RunStreamWorker 返回 %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ctx 取消后未退出")
+	}
+}
@@ -0,0 +1,135 @@
+package specialdb
+
+/**
+  事务：开启、提交或回滚，fn 中 panic 时回滚并转为错误返回
+  在事务内再次调用 WithTx 时使用保存点（SAVEPOINT）实现嵌套，内层失败只回滚到保存点
+  死锁或序列化失败（SQL Server 1205，Postgres 40001/40P01，MySQL 1213）时整个事务自动重试，fn 需可重复执行
+    err := specialdb.WithTx(ctx, db, func(tx *gorm.DB) error {
+        if err := tx.Create(&order).Error; err != nil {
+            return err
+        }
+        return specialdb.WithTx(ctx, tx, func(tx *gorm.DB) error { ... }) // 嵌套
+    }, specialdb.TxIsolation(sql.LevelSerializable))
+*/
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// TxOption WithTx 可选参数
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	sqlOptions *sql.TxOptions
+	maxRetries int
+	backoff    time.Duration
+}
+
+// TxIsolation 事务隔离级别
+func TxIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		if o.sqlOptions == nil {
+			o.sqlOptions = &sql.TxOptions{}
+		}
+		o.sqlOptions.Isolation = level
+	}
+}
+
+// TxReadOnly 只读事务
+func TxReadOnly() TxOption {
+	return func(o *txOptions) {
+		if o.sqlOptions == nil {
+			o.sqlOptions = &sql.TxOptions{}
+		}
+		o.sqlOptions.ReadOnly = true
+	}
+}
+
+// TxRetry 死锁或序列化失败时的最大重试次数，默认3，0 表示不重试
+func TxRetry(maxRetries int) TxOption {
+	return func(o *txOptions) { o.maxRetries = maxRetries }
+}
+
+// TxBackoff 首次重试前的等待时间，默认50毫秒，之后每次翻倍并加随机抖动
+func TxBackoff(backoff time.Duration) TxOption {
+	return func(o *txOptions) { o.backoff = backoff }
+}
+
+// WithTx 在事务中执行 fn：fn 返回错误或 panic 时回滚，否则提交；db 已在事务中时使用保存点嵌套（嵌套事务不重试）
+func WithTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error, opts ...TxOption) error {
+	o := txOptions{maxRetries: 3, backoff: 50 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	db = db.WithContext(ctx)
+	var sqlOptions []*sql.TxOptions
+	if o.sqlOptions != nil {
+		sqlOptions = append(sqlOptions, o.sqlOptions)
+	}
+	if inTx(db) {
+		// 死锁会使整个外层事务失效，只能由最外层重试
+		return db.Transaction(safeTxFunc(fn), sqlOptions...)
+	}
+	backoff := o.backoff
+	for attempt := 0; ; attempt++ {
+		err := db.Transaction(safeTxFunc(fn), sqlOptions...)
+		if err == nil || attempt >= o.maxRetries || !IsRetryableTxError(err) {
+			return err
+		}
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)+1))
+		fmt.Printf("------------WithTx: 第 %d 次执行失败，%v 后重试:%v------------\n", attempt+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// inTx db 是否已在事务中
+func inTx(db *gorm.DB) bool {
+	committer, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok && committer != nil
+}
+
+// safeTxFunc 将 fn 中的 panic 转为错误，使事务回滚
+func safeTxFunc(fn func(tx *gorm.DB) error) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("------------WithTx: 事务执行异常:%v\n%s------------\n", r, debug.Stack())
+				err = fmt.Errorf("事务执行异常: %v", r)
+			}
+		}()
+		return fn(tx)
+	}
+}
+
+// IsRetryableTxError 是否为可重试的死锁或序列化失败错误
+func IsRetryableTxError(err error) bool {
+	// postgres（pgconn.PgError）
+	var pgErr interface{ SQLState() string }
+	if errors.As(err, &pgErr) {
+		switch pgErr.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	// sqlserver（mssql.Error）
+	var mssqlErr interface{ SQLErrorNumber() int32 }
+	if errors.As(err, &mssqlErr) && mssqlErr.SQLErrorNumber() == 1205 {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1213
+}
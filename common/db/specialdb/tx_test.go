@@ -0,0 +1,163 @@
+package specialdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordDriver 只记录事务语句的 database/sql 驱动
+type recordDriver struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (d *recordDriver) record(stmt string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, stmt)
+}
+
+func (d *recordDriver) statements() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return strings.Join(d.log, ";")
+}
+
+func (d *recordDriver) Open(string) (driver.Conn, error) { return &recordConn{d: d}, nil }
+
+type recordConn struct{ d *recordDriver }
+
+func (c *recordConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *recordConn) Close() error                        { return nil }
+func (c *recordConn) Begin() (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return c, nil
+}
+func (c *recordConn) Commit() error {
+	c.d.record("COMMIT")
+	return nil
+}
+func (c *recordConn) Rollback() error {
+	c.d.record("ROLLBACK")
+	return nil
+}
+func (c *recordConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	// 保存点名称随机，只记录语句类型
+	if i := strings.LastIndex(query, " sp"); i > 0 {
+		query = query[:i]
+	}
+	c.d.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+var recordDrivers atomic.Int32
+
+func newRecordDB(t *testing.T) (*gorm.DB, *recordDriver) {
+	t.Helper()
+	d := &recordDriver{}
+	name := fmt.Sprintf("record-%d", recordDrivers.Add(1))
+	sql.Register(name, d)
+	sqlDB, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	db, err := gorm.Open(gormmysql.New(gormmysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}),
+		&gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, d
+}
+
+func TestWithTx(t *testing.T) {
+	db, d := newRecordDB(t)
+	ctx := context.Background()
+
+	// 内层失败只回滚到保存点
+	err := WithTx(ctx, db, func(tx *gorm.DB) error {
+		inner := WithTx(ctx, tx, func(tx *gorm.DB) error { return errors.New("库存不足") })
+		if inner == nil || inner.Error() != "库存不足" {
+			t.Fatalf("内层错误 = %v", inner)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.statements(), "BEGIN;SAVEPOINT;ROLLBACK TO SAVEPOINT;COMMIT"; got != want {
+		t.Fatalf("语句 = %s, want %s", got, want)
+	}
+
+	// panic 转为错误并回滚
+	d.log = nil
+	err = WithTx(ctx, db, func(tx *gorm.DB) error { panic("空指针") })
+	if err == nil || !strings.Contains(err.Error(), "空指针") || d.statements() != "BEGIN;ROLLBACK" {
+		t.Fatalf("panic err = %v, 语句 = %s", err, d.statements())
+	}
+
+	// 死锁重试，原错误不丢失
+	d.log = nil
+	attempts := 0
+	err = WithTx(ctx, db, func(tx *gorm.DB) error {
+		if attempts++; attempts < 3 {
+			return &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+		}
+		return nil
+	}, TxBackoff(time.Millisecond))
+	if err != nil || attempts != 3 || d.statements() != "BEGIN;ROLLBACK;BEGIN;ROLLBACK;BEGIN;COMMIT" {
+		t.Fatalf("重试 err = %v, attempts = %d, 语句 = %s", err, attempts, d.statements())
+	}
+	attempts = 0
+	err = WithTx(ctx, db, func(tx *gorm.DB) error {
+		attempts++
+		return &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}
+	}, TxRetry(1), TxBackoff(time.Millisecond))
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || attempts != 2 {
+		t.Fatalf("超过重试次数 err = %v, attempts = %d", err, attempts)
+	}
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "pg: " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type mssqlError int32
+
+func (e mssqlError) Error() string         { return fmt.Sprintf("mssql: %d", int32(e)) }
+func (e mssqlError) SQLErrorNumber() int32 { return int32(e) }
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{sqlStateError("40001"), true},
+		{sqlStateError("40P01"), true},
+		{sqlStateError("23505"), false},
+		{fmt.Errorf("提交失败: %w", mssqlError(1205)), true},
+		{mssqlError(2627), false},
+		{&mysql.MySQLError{Number: 1213}, true},
+		{errors.New("deadlock"), false},
+	}
+	for _, tc := range cases {
+		if got := IsRetryableTxError(tc.err); got != tc.want {
+			t.Errorf("IsRetryableTxError(%v) = %v", tc.err, got)
+		}
+	}
+}
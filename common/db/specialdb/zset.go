@@ -0,0 +1,122 @@
+package specialdb
+
+/**
+  有序集合操作（排行榜、延时任务等）
+    min/max 参数支持 "-inf"、"+inf" 与开区间 "(100"
+*/
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// ZMember 有序集合成员
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// zMembers 将 [member, score, ...] 结果转为 []ZMember
+func zMembers(reply interface{}, err error) ([]ZMember, error) {
+	values, err := redis.Strings(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ZMember, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := strconv.ParseFloat(values[i+1], 64)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ZMember{Member: values[i], Score: score})
+	}
+	return result, nil
+}
+
+// ZAdd 添加成员或更新分数，返回新增的成员数
+func (s *RedisTemplate) ZAdd(key string, members []ZMember, ctx context.Context) (int64, error) {
+	args := make([]interface{}, 0, len(members)*2)
+	for _, m := range members {
+		args = append(args, m.Score, m.Member)
+	}
+	return doAs(s, redis.Int64, "ZADD", key, args, ctx)
+}
+
+// ZIncrBy 成员分数增加 incr，返回新分数
+func (s *RedisTemplate) ZIncrBy(key, member string, incr float64, ctx context.Context) (float64, error) {
+	return doAs(s, redis.Float64, "ZINCRBY", key, []interface{}{incr, member}, ctx)
+}
+
+// ZScore 成员分数，成员不存在时返回 ErrNotFound
+func (s *RedisTemplate) ZScore(key, member string, ctx context.Context) (float64, error) {
+	return doAs(s, redis.Float64, "ZSCORE", key, []interface{}{member}, ctx)
+}
+
+// ZRem 删除成员，返回删除的成员数
+func (s *RedisTemplate) ZRem(key string, members []string, ctx context.Context) (int64, error) {
+	args := make([]interface{}, len(members))
+	for i, m := range members {
+		args[i] = m
+	}
+	return doAs(s, redis.Int64, "ZREM", key, args, ctx)
+}
+
+// ZRangeByScore 按分数从小到大读取 [min, max] 区间的成员；count<=0 表示不限制数量
+func (s *RedisTemplate) ZRangeByScore(key, min, max string, offset, count int64, ctx context.Context) ([]ZMember, error) {
+	args := []interface{}{min, max, "WITHSCORES"}
+	if count > 0 {
+		args = append(args, "LIMIT", offset, count)
+	}
+	return doAs(s, zMembers, "ZRANGEBYSCORE", key, args, ctx)
+}
+
+// ZRevRangeByScore 按分数从大到小读取 [min, max] 区间的成员；count<=0 表示不限制数量
+func (s *RedisTemplate) ZRevRangeByScore(key, max, min string, offset, count int64, ctx context.Context) ([]ZMember, error) {
+	args := []interface{}{max, min, "WITHSCORES"}
+	if count > 0 {
+		args = append(args, "LIMIT", offset, count)
+	}
+	return doAs(s, zMembers, "ZREVRANGEBYSCORE", key, args, ctx)
+}
+
+// ZRange 按排名从小到大读取 [start, stop] 的成员，负数表示倒数
+func (s *RedisTemplate) ZRange(key string, start, stop int64, ctx context.Context) ([]ZMember, error) {
+	return doAs(s, zMembers, "ZRANGE", key, []interface{}{start, stop, "WITHSCORES"}, ctx)
+}
+
+// ZRevRange 按排名从大到小读取 [start, stop] 的成员（排行榜）
+func (s *RedisTemplate) ZRevRange(key string, start, stop int64, ctx context.Context) ([]ZMember, error) {
+	return doAs(s, zMembers, "ZREVRANGE", key, []interface{}{start, stop, "WITHSCORES"}, ctx)
+}
+
+// ZRank 成员从小到大的排名（从 0 开始），成员不存在时返回 ErrNotFound
+func (s *RedisTemplate) ZRank(key, member string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "ZRANK", key, []interface{}{member}, ctx)
+}
+
+// ZRevRank 成员从大到小的排名（从 0 开始），成员不存在时返回 ErrNotFound
+func (s *RedisTemplate) ZRevRank(key, member string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "ZREVRANK", key, []interface{}{member}, ctx)
+}
+
+// ZCard 成员数
+func (s *RedisTemplate) ZCard(key string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "ZCARD", key, nil, ctx)
+}
+
+// ZCount 分数在 [min, max] 区间的成员数
+func (s *RedisTemplate) ZCount(key, min, max string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "ZCOUNT", key, []interface{}{min, max}, ctx)
+}
+
+// ZRemRangeByScore 删除分数在 [min, max] 区间的成员，返回删除数
+func (s *RedisTemplate) ZRemRangeByScore(key, min, max string, ctx context.Context) (int64, error) {
+	return doAs(s, redis.Int64, "ZREMRANGEBYSCORE", key, []interface{}{min, max}, ctx)
+}
+
+// FormatScore 分数转为 ZRangeByScore 等方法的区间参数
+func FormatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
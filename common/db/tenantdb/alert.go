@@ -0,0 +1,209 @@
+package tenantdb
+
+/**
+  租户数据源告警：健康检查失败、连接池耗尽、主备切换时通过 AlertSink 发送告警（企业微信、Webhook、日志）
+  默认只记录日志，发送到企业微信等外部渠道需调用 SetAlertSink 开启：
+    tenantdb.SetAlertSink(tenantdb.WorkWxSink{ChatID: chatID})
+  同一租户同类告警按间隔限流，避免单个异常租户刷屏
+*/
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"github.com/soedev/soelib/common/utils"
+)
+
+// 告警类型
+const (
+	AlertHealthCheckFailed = "health_check_failed" // 健康检查失败
+	AlertPoolExhausted     = "pool_exhausted"      // 连接池耗尽（出现等待连接）
+	AlertFailover          = "failover"            // 切换到备用数据源
+	AlertFailBack          = "failback"            // 切回主数据源
+)
+
+// Alert 告警内容
+type Alert struct {
+	TenantID   string       `json:"tenantId"`
+	Kind       string       `json:"kind"`
+	Message    string       `json:"message"`
+	Stats      *sql.DBStats `json:"stats,omitempty"`
+	Suppressed int          `json:"suppressed"` // 上次发送后被限流抑制的同类告警数
+	Time       time.Time    `json:"time"`
+}
+
+// Text 告警文本
+func (a Alert) Text() string {
+	text := fmt.Sprintf("租户：%s %s", a.TenantID, a.Message)
+	if a.Stats != nil {
+		text += fmt.Sprintf("   最大连接：%d,打开连接：%d，使用连接：%d，等待连接：%d", a.Stats.MaxOpenConnections, a.Stats.OpenConnections, a.Stats.InUse, a.Stats.WaitCount)
+	}
+	if a.Suppressed > 0 {
+		text += fmt.Sprintf("（期间另有 %d 条同类告警）", a.Suppressed)
+	}
+	return text
+}
+
+// AlertSink 告警发送
+type AlertSink interface {
+	Send(ctx context.Context, alert Alert) error
+}
+
+// WorkWxSink 企业微信群告警
+type WorkWxSink struct {
+	ChatID  string // 群 ID，默认 utils.DefaultRegChatID
+	APIPath string // 接口地址，默认 utils.WorkWxAPIPath
+	Token   string // 访问令牌，默认 utils.WorkWxRestTokenStr
+}
+
+func (s WorkWxSink) Send(_ context.Context, alert Alert) error {
+	chatID, apiPath, token := s.ChatID, s.APIPath, s.Token
+	if chatID == "" {
+		chatID = utils.DefaultRegChatID
+	}
+	if apiPath == "" {
+		apiPath = utils.WorkWxAPIPath
+	}
+	if token == "" {
+		token = utils.WorkWxRestTokenStr
+	}
+	utils.SendMsgToWorkWx(chatID, alert.Text(), apiPath, token)
+	return nil
+}
+
+// WebhookSink 以 JSON 格式 POST 告警到指定地址
+type WebhookSink struct {
+	URL     string
+	Header  map[string]string
+	Timeout time.Duration // 默认10秒
+}
+
+func (s WebhookSink) Send(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json;charset=utf-8")
+	for k, v := range s.Header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("告警接口返回状态码：%d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LogSink 仅记录日志
+type LogSink struct{}
+
+func (LogSink) Send(_ context.Context, alert Alert) error {
+	soelog.Logger.Warn("[租户数据源告警] " + alert.Text())
+	return nil
+}
+
+var (
+	alertSink     AlertSink = LogSink{}
+	alertInterval           = 10 * time.Minute
+	alertMu       sync.Mutex
+	alertLast     = map[string]*alertState{} // tenantID:kind -> 限流状态
+	alertPruned   time.Time                  // 最近一次清理过期限流状态的时间
+	alertStateTTL = 24 * time.Hour           // 有抑制计数的限流状态最长保留时间
+)
+
+type alertState struct {
+	sentAt     time.Time
+	suppressed int
+}
+
+// SetAlertSink 设置告警发送方式，为 nil 时关闭告警；默认只记录日志（LogSink）
+func SetAlertSink(sink AlertSink) {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	alertSink = sink
+}
+
+// SetAlertInterval 设置同一租户同类告警的最小发送间隔（默认10分钟，0 表示不限流）
+func SetAlertInterval(interval time.Duration) {
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	alertInterval = interval
+}
+
+// emitAlert 限流后异步发送告警
+func emitAlert(alert Alert) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	alertMu.Lock()
+	sink := alertSink
+	if sink == nil {
+		alertMu.Unlock()
+		return
+	}
+	// 不限流时不保存状态
+	if alertInterval > 0 {
+		if alert.Time.Sub(alertPruned) >= alertInterval {
+			pruneAlertStates(alert.Time)
+		}
+		key := alert.TenantID + ":" + alert.Kind
+		state, ok := alertLast[key]
+		if !ok {
+			state = &alertState{}
+			alertLast[key] = state
+		}
+		if alert.Time.Sub(state.sentAt) < alertInterval {
+			state.suppressed++
+			alertMu.Unlock()
+			return
+		}
+		alert.Suppressed = state.suppressed
+		state.sentAt = alert.Time
+		state.suppressed = 0
+	}
+	alertMu.Unlock()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				soelog.Logger.Error(fmt.Sprintf("发送租户数据源告警异常: %v", r))
+			}
+		}()
+		if err := sink.Send(context.Background(), alert); err != nil {
+			soelog.Logger.Warn(fmt.Sprintf("发送租户数据源告警失败: %v", err))
+		}
+	}()
+}
+
+// pruneAlertStates 清理过期的限流状态，避免状态随租户数无限增长（调用方需持有 alertMu）
+// 有抑制计数的状态保留到下次发送时上报，超过 alertStateTTL 未再出现时同样清理
+func pruneAlertStates(now time.Time) {
+	for key, state := range alertLast {
+		idle := now.Sub(state.sentAt)
+		if idle >= alertInterval && (state.suppressed == 0 || idle >= alertStateTTL) {
+			delete(alertLast, key)
+		}
+	}
+	alertPruned = now
+}
@@ -0,0 +1,96 @@
+package tenantdb
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordSink struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (s *recordSink) Send(_ context.Context, alert Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerts = append(s.alerts, alert)
+	return nil
+}
+
+func (s *recordSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.alerts)
+}
+
+func TestEmitAlert(t *testing.T) {
+	sink := &recordSink{}
+	SetAlertSink(sink)
+	SetAlertInterval(time.Minute)
+	defer func() {
+		SetAlertSink(LogSink{})
+		SetAlertInterval(10 * time.Minute)
+	}()
+	alertMu.Lock()
+	alertLast = map[string]*alertState{}
+	alertPruned = time.Time{}
+	alertMu.Unlock()
+
+	now := time.Now()
+	// 同一租户同类告警限流，不同租户或类型不受影响
+	emitAlert(Alert{TenantID: "1001", Kind: AlertHealthCheckFailed, Time: now})
+	emitAlert(Alert{TenantID: "1001", Kind: AlertHealthCheckFailed, Time: now.Add(time.Second)})
+	emitAlert(Alert{TenantID: "1001", Kind: AlertHealthCheckFailed, Time: now.Add(2 * time.Second)})
+	emitAlert(Alert{TenantID: "1001", Kind: AlertPoolExhausted, Time: now})
+	emitAlert(Alert{TenantID: "1002", Kind: AlertHealthCheckFailed, Time: now})
+	emitAlert(Alert{TenantID: "1001", Kind: AlertHealthCheckFailed, Time: now.Add(2 * time.Minute)})
+
+	deadline := time.Now().Add(time.Second)
+	for sink.count() < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sink.count() != 4 {
+		t.Fatalf("期望发送 4 条告警，实际 %d", sink.count())
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	for _, alert := range sink.alerts {
+		if alert.TenantID == "1001" && alert.Kind == AlertHealthCheckFailed && alert.Time.After(now) && alert.Suppressed != 2 {
+			t.Errorf("期望抑制计数 2，实际 %d", alert.Suppressed)
+		}
+	}
+}
+
+func TestPruneAlertStates(t *testing.T) {
+	SetAlertSink(&recordSink{})
+	SetAlertInterval(time.Minute)
+	defer func() {
+		SetAlertSink(LogSink{})
+		SetAlertInterval(10 * time.Minute)
+	}()
+	alertMu.Lock()
+	alertLast = map[string]*alertState{}
+	alertPruned = time.Time{}
+	alertMu.Unlock()
+
+	now := time.Now()
+	emitAlert(Alert{TenantID: "1001", Kind: AlertHealthCheckFailed, Time: now})
+	emitAlert(Alert{TenantID: "1002", Kind: AlertHealthCheckFailed, Time: now})
+	emitAlert(Alert{TenantID: "1002", Kind: AlertHealthCheckFailed, Time: now.Add(30 * time.Second)})
+	// 超过限流间隔后清理过期状态，有抑制计数的保留
+	emitAlert(Alert{TenantID: "1003", Kind: AlertHealthCheckFailed, Time: now.Add(2 * time.Minute)})
+	alertMu.Lock()
+	if len(alertLast) != 2 || alertLast["1001:"+AlertHealthCheckFailed] != nil {
+		alertMu.Unlock()
+		t.Fatalf("过期限流状态未清理: %v", alertLast)
+	}
+	alertMu.Unlock()
+	emitAlert(Alert{TenantID: "1003", Kind: AlertHealthCheckFailed, Time: now.Add(25 * time.Hour)})
+	alertMu.Lock()
+	defer alertMu.Unlock()
+	if len(alertLast) != 1 || alertLast["1003:"+AlertHealthCheckFailed] == nil {
+		t.Fatalf("过期限流状态未清理: %v", alertLast)
+	}
+}
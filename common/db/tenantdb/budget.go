@@ -0,0 +1,257 @@
+package tenantdb
+
+/**
+  租户连接池全局连接预算与空闲回收（LRU）
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"gorm.io/gorm"
+)
+
+// ErrConnBudgetExceeded 连接预算不足（租户连接池超过全局最大连接数，或回收后仍有延迟关闭的连接占用预算）
+var ErrConnBudgetExceeded = errors.New("租户连接池超过全局最大连接数限制")
+
+// PoolLimitConfig 连接池全局限制配置
+type PoolLimitConfig struct {
+	MaxTotalConns int           // 进程内所有租户连接池最大连接数之和，超出时按 LRU 回收（0 表示不限制）
+	IdleTTL       time.Duration // 租户连接池空闲超过该时间后回收（0 表示不回收）
+	EvictInterval time.Duration // 空闲回收检查间隔（默认1分钟）
+}
+
+var (
+	poolLimit     PoolLimitConfig
+	poolLimitMu   sync.RWMutex
+	budgetMu      sync.Mutex // 串行化连接预算检查，避免并发创建时超出预算
+	reservedConns int        // 已预留预算、正在打开的连接池连接数（budgetMu 保护）
+	draining      sync.Map   // 延迟关闭中的连接池（*gorm.DB），其连接计入预算
+	drainMaxWait  = time.Minute
+	drainInterval = time.Second
+	limitChanged  = make(chan struct{}, 1) // 通知健康检查协程按新的 EvictInterval 重置定时器
+)
+
+// SetPoolLimit 设置连接池全局限制
+func SetPoolLimit(config PoolLimitConfig) {
+	if config.EvictInterval <= 0 {
+		config.EvictInterval = time.Minute
+	}
+	poolLimitMu.Lock()
+	poolLimit = config
+	poolLimitMu.Unlock()
+	select {
+	case limitChanged <- struct{}{}:
+	default:
+	}
+	soelog.Logger.Info(fmt.Sprintf("租户连接池全局限制已设置：最大连接数 %d，空闲回收时间 %v", config.MaxTotalConns, config.IdleTTL))
+}
+
+func getPoolLimit() PoolLimitConfig {
+	poolLimitMu.RLock()
+	defer poolLimitMu.RUnlock()
+	return poolLimit
+}
+
+// evictInterval 空闲回收检查间隔
+func evictInterval() time.Duration {
+	if interval := getPoolLimit().EvictInterval; interval > 0 {
+		return interval
+	}
+	return time.Minute
+}
+
+// touch 记录最近使用时间
+func (p *tenantPool) touch() {
+	p.lastUsed.Store(time.Now().UnixNano())
+}
+
+// maxOpenConns 连接池最大连接数（含只读副本）
+func (p *tenantPool) maxOpenConns() int {
+	db := p.db.Load()
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0
+	}
+	total := sqlDB.Stats().MaxOpenConnections
+	for _, replica := range replicasOf(db) {
+		total += replica.db.Stats().MaxOpenConnections
+	}
+	return total
+}
+
+// openConns 连接池当前打开的连接数（含只读副本）
+func openConns(db *gorm.DB) int {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return 0
+	}
+	total := sqlDB.Stats().OpenConnections
+	for _, replica := range replicasOf(db) {
+		total += replica.db.Stats().OpenConnections
+	}
+	return total
+}
+
+// drainingConns 延迟关闭中的连接池仍打开的连接数
+func drainingConns() int {
+	total := 0
+	draining.Range(func(key, _ interface{}) bool {
+		total += openConns(key.(*gorm.DB))
+		return true
+	})
+	return total
+}
+
+// connsOf 按数据源配置计算连接池最大连接数（含只读副本），与 setPoolSize 保持一致
+func connsOf(tenantDataSource TenantDataSource) int {
+	size := tenantDataSource.MaxPoolSize
+	if size == 0 {
+		size = 10
+	}
+	return size * (1 + len(tenantDataSource.replicaURLs()))
+}
+
+// reserveConns 打开新连接池前预留连接预算，超出预算时回收最久未使用的租户连接池（keep 为即将被替换的连接池，不回收）
+// 已缓存的连接池按最大连接数计算，延迟关闭中的连接池按仍打开的连接数计算；回收全部仍不足时不回收，返回 ErrConnBudgetExceeded
+func reserveConns(need int, keep *tenantPool) error {
+	budgetMu.Lock()
+	defer budgetMu.Unlock()
+
+	limit := getPoolLimit()
+	if limit.MaxTotalConns > 0 {
+		if need > limit.MaxTotalConns {
+			return ErrConnBudgetExceeded
+		}
+		pools, total := listPools()
+		total += reservedConns + drainingConns()
+		// 按最近使用时间升序，优先回收最久未使用的
+		sort.Slice(pools, func(i, j int) bool {
+			return pools[i].lastUsed.Load() < pools[j].lastUsed.Load()
+		})
+		var evicts []*tenantPool
+		for _, old := range pools {
+			if total+need <= limit.MaxTotalConns {
+				break
+			}
+			if old == keep {
+				continue
+			}
+			// 回收后空闲连接立即关闭，使用中的连接延迟关闭前仍占用预算
+			sqlDB, err := old.db.Load().DB()
+			inUse := 0
+			if err == nil {
+				inUse = sqlDB.Stats().InUse
+			}
+			total -= old.maxOpenConns() - inUse
+			evicts = append(evicts, old)
+		}
+		if total+need > limit.MaxTotalConns {
+			return ErrConnBudgetExceeded
+		}
+		for _, old := range evicts {
+			soelog.Logger.Info(fmt.Sprintf("连接预算不足，回收最久未使用的租户[%s]连接池", old.tenantID))
+			evictPool(old)
+		}
+	}
+	reservedConns += need
+	return nil
+}
+
+// releaseConns 释放预留的连接预算（打开连接池失败时调用）
+func releaseConns(reserved int) {
+	budgetMu.Lock()
+	defer budgetMu.Unlock()
+	reservedConns -= reserved
+}
+
+// openReserved 预留连接预算后为 pool 打开新的数据源连接，用于替换连接池的连接（主备切换、重新加载）
+// 调用方替换或丢弃连接后调用 release 释放预留：替换后由连接池计入预算，旧连接池延迟关闭期间按打开的连接数计入
+func openReserved(ctx context.Context, pool *tenantPool, tenantDataSource TenantDataSource) (db *gorm.DB, release func(), err error) {
+	need := connsOf(tenantDataSource)
+	if err = reserveConns(need, pool); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if db == nil {
+			releaseConns(need)
+		}
+	}()
+	if db, err = openDB(ctx, tenantDataSource, pool.opt); err != nil {
+		return nil, nil, err
+	}
+	return db, func() { releaseConns(need) }, nil
+}
+
+// storePool 保存新连接池，预留的连接预算转为连接池占用
+func storePool(pool *tenantPool, reserved int) {
+	budgetMu.Lock()
+	defer budgetMu.Unlock()
+	reservedConns -= reserved
+	pool.touch()
+	dbMap.Store(pool.tenantID, pool)
+}
+
+// listPools 列出所有缓存的连接池及最大连接数之和
+func listPools() ([]*tenantPool, int) {
+	var pools []*tenantPool
+	total := 0
+	dbMap.Range(func(_, value interface{}) bool {
+		pool := value.(*tenantPool)
+		pools = append(pools, pool)
+		total += pool.maxOpenConns()
+		return true
+	})
+	return pools, total
+}
+
+// evictIdlePools 回收空闲超过 IdleTTL 的租户连接池
+func evictIdlePools() {
+	limit := getPoolLimit()
+	if limit.IdleTTL <= 0 {
+		return
+	}
+	deadline := time.Now().Add(-limit.IdleTTL).UnixNano()
+	dbMap.Range(func(_, value interface{}) bool {
+		pool := value.(*tenantPool)
+		if pool.lastUsed.Load() < deadline {
+			soelog.Logger.Info(fmt.Sprintf("租户[%s]连接池空闲超过 %v，将回收", pool.tenantID, limit.IdleTTL))
+			evictPool(pool)
+		}
+		return true
+	})
+}
+
+// evictPool 从缓存移除连接池，并在正在执行的查询完成后关闭
+func evictPool(pool *tenantPool) {
+	if dbMap.CompareAndDelete(pool.tenantID, pool) {
+		drainAndClose(pool.db.Load())
+	}
+}
+
+// drainAndClose 立即释放空闲连接，延迟 drainDelay 后等待使用中的连接归还再关闭连接池（最长等待 drainMaxWait）
+func drainAndClose(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return
+	}
+	draining.Store(db, struct{}{})
+	sqlDB.SetMaxIdleConns(0)
+	for _, replica := range replicasOf(db) {
+		replica.db.SetMaxIdleConns(0)
+	}
+	go func() {
+		defer draining.Delete(db)
+		time.Sleep(drainDelay)
+		deadline := time.Now().Add(drainMaxWait)
+		for sqlDB.Stats().InUse > 0 && time.Now().Before(deadline) {
+			time.Sleep(drainInterval)
+		}
+		closeDB(db)
+	}()
+}
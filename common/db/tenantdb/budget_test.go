@@ -0,0 +1,118 @@
+package tenantdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+)
+
+func TestConnBudget(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	d := useFakeDB(t)
+	SetPoolLimit(PoolLimitConfig{MaxTotalConns: 25})
+	defer SetPoolLimit(PoolLimitConfig{})
+
+	provider := NewStaticProvider([]DataSourceConfig{
+		{TenantID: 9101, URL: "budget-a", MaxPoolSize: 10},
+		{TenantID: 9102, URL: "budget-b", MaxPoolSize: 10},
+		{TenantID: 9103, URL: "budget-c", MaxPoolSize: 10},
+		{TenantID: 9104, URL: "budget-d", MaxPoolSize: 30},
+		{TenantID: 9105, URL: "budget-e", MaxPoolSize: 25},
+	})
+	ctx := context.Background()
+	opt := &OptSQL{}
+	t.Cleanup(func() {
+		for _, id := range []string{"9101", "9102", "9103", "9104", "9105"} {
+			if pool, ok := dbMap.LoadAndDelete(id); ok {
+				closeDB(pool.(*tenantPool).db.Load())
+			}
+		}
+	})
+	cached := func(id string) bool {
+		_, ok := dbMap.Load(id)
+		return ok
+	}
+
+	if _, err := getDbFromMap(ctx, "9101", provider, opt, false); err != nil {
+		t.Fatal(err)
+	}
+	b, err := getDbFromMap(ctx, "9102", provider, opt, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9102 有使用中的连接，回收后延迟关闭期间仍占用预算
+	sqlB, _ := b.DB()
+	conn, err := sqlB.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	time.Sleep(time.Millisecond)
+	if _, err = getDbFromMap(ctx, "9101", provider, opt, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 超出预算时回收最久未使用的 9102
+	if _, err = getDbFromMap(ctx, "9103", provider, opt, false); err != nil {
+		t.Fatal(err)
+	}
+	if !cached("9101") || cached("9102") || !cached("9103") {
+		t.Fatal("应回收最久未使用的租户连接池")
+	}
+	if n := drainingConns(); n != 1 {
+		t.Fatalf("延迟关闭中的连接数 = %d, want 1", n)
+	}
+
+	// 单个连接池超过预算，不打开连接
+	d.setDown("budget-d", true)
+	if _, err = getDbFromMap(ctx, "9104", provider, opt, false); !errors.Is(err, ErrConnBudgetExceeded) {
+		t.Fatalf("期望 ErrConnBudgetExceeded，实际 %v", err)
+	}
+	// 回收全部连接池后，延迟关闭的连接仍使预算不足：不回收、不打开连接
+	d.setDown("budget-e", true)
+	if _, err = getDbFromMap(ctx, "9105", provider, opt, false); !errors.Is(err, ErrConnBudgetExceeded) {
+		t.Fatalf("期望 ErrConnBudgetExceeded，实际 %v", err)
+	}
+	if !cached("9101") || !cached("9103") {
+		t.Fatal("预算不足时不应回收连接池")
+	}
+	if err = checkUnavailable("9105"); err != nil {
+		t.Fatalf("预算不足不应进入失败退避: %v", err)
+	}
+}
+
+func TestEvictIdlePools(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	useFakeDB(t)
+	SetPoolLimit(PoolLimitConfig{IdleTTL: time.Minute})
+	defer SetPoolLimit(PoolLimitConfig{})
+
+	provider := NewStaticProvider([]DataSourceConfig{
+		{TenantID: 9201, URL: "idle-a"},
+		{TenantID: 9202, URL: "idle-b"},
+	})
+	for _, id := range []string{"9201", "9202"} {
+		if _, err := getDbFromMap(context.Background(), id, provider, &OptSQL{}, false); err != nil {
+			t.Fatal(err)
+		}
+		defer func(id string) {
+			if pool, ok := dbMap.LoadAndDelete(id); ok {
+				closeDB(pool.(*tenantPool).db.Load())
+			}
+		}(id)
+	}
+	value, _ := dbMap.Load("9201")
+	value.(*tenantPool).lastUsed.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+
+	evictIdlePools()
+	if _, ok := dbMap.Load("9201"); ok {
+		t.Fatal("空闲超时的连接池应被回收")
+	}
+	if _, ok := dbMap.Load("9202"); !ok {
+		t.Fatal("未超时的连接池不应被回收")
+	}
+}
@@ -23,6 +23,7 @@ type (
 		PoolSize        int           `json:"poolSize"` //空闲
 		MaxPoolSize     int           `json:"maxPoolSize"`
 		ExpMinute       time.Duration `json:"expMinute"`
+		ReplicaURLs     string        `json:"replicaUrls"` //只读副本 JDBC 地址（可选），多个以 | 分隔
 	}
 	TDSRepository struct {
 		db *gorm.DB
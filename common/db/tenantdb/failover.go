@@ -0,0 +1,224 @@
+package tenantdb
+
+/**
+  主备数据源自动切换：健康检查发现主库连续失败时切换到备用数据源（crm.tenant_datasource_back），
+  主库恢复后自动切回
+*/
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"gorm.io/gorm"
+)
+
+// FailoverPolicy 主备自动切换策略
+type FailoverPolicy struct {
+	FailureThreshold int           // 主库连续健康检查失败次数达到阈值后切换到备库（默认3次）
+	CoolDown         time.Duration // 切换后的冷却时间，冷却期内不会再次切换（默认5分钟）
+	FailBack         bool          // 主库恢复后是否自动切回
+	RecoverThreshold int           // 主库连续健康检查成功次数达到阈值后切回（默认3次）
+}
+
+// DefaultFailoverPolicy 返回默认切换策略（自动切回）
+func DefaultFailoverPolicy() *FailoverPolicy {
+	return &FailoverPolicy{
+		FailureThreshold: 3,
+		CoolDown:         5 * time.Minute,
+		FailBack:         true,
+		RecoverThreshold: 3,
+	}
+}
+
+// FailoverEvent 主备切换事件
+type FailoverEvent struct {
+	TenantID string
+	ToBackup bool      // true=切换到备库 false=切回主库
+	Reason   string    // 切换原因
+	Time     time.Time // 切换时间
+}
+
+var (
+	globalFailoverPolicy *FailoverPolicy
+	failoverMu           sync.RWMutex
+	failoverHandlers     []func(FailoverEvent)
+)
+
+// SetFailoverPolicy 设置全局主备切换策略，为 nil 时关闭自动切换（健康检查失败直接移除缓存）
+func SetFailoverPolicy(policy *FailoverPolicy) {
+	failoverMu.Lock()
+	defer failoverMu.Unlock()
+	globalFailoverPolicy = policy
+}
+
+// OnFailover 注册主备切换事件回调
+func OnFailover(handler func(FailoverEvent)) {
+	failoverMu.Lock()
+	defer failoverMu.Unlock()
+	failoverHandlers = append(failoverHandlers, handler)
+}
+
+// emitFailoverEvent 记录日志并通知所有回调
+func emitFailoverEvent(event FailoverEvent) {
+	if event.ToBackup {
+		soelog.Logger.Warn(fmt.Sprintf("租户[%s]主数据源异常，已切换到备用数据源: %s", event.TenantID, event.Reason))
+		emitAlert(Alert{TenantID: event.TenantID, Kind: AlertFailover, Message: "主数据源异常，已切换到备用数据源：" + event.Reason, Time: event.Time})
+	} else {
+		soelog.Logger.Info(fmt.Sprintf("租户[%s]主数据源已恢复，已切回主数据源", event.TenantID))
+		emitAlert(Alert{TenantID: event.TenantID, Kind: AlertFailBack, Message: "主数据源已恢复，已切回主数据源", Time: event.Time})
+	}
+	failoverMu.RLock()
+	handlers := append([]func(FailoverEvent){}, failoverHandlers...)
+	failoverMu.RUnlock()
+	for _, handler := range handlers {
+		go func(handler func(FailoverEvent)) {
+			defer func() {
+				if r := recover(); r != nil {
+					soelog.Logger.Error(fmt.Sprintf("主备切换事件回调异常: %v", r))
+				}
+			}()
+			handler(event)
+		}(handler)
+	}
+}
+
+// failoverPolicy 取得连接池生效的切换策略
+func (p *tenantPool) failoverPolicy() *FailoverPolicy {
+	if p.opt != nil && p.opt.Failover != nil {
+		return p.opt.Failover
+	}
+	failoverMu.RLock()
+	defer failoverMu.RUnlock()
+	return globalFailoverPolicy
+}
+
+// handleFailover 根据健康检查结果处理主备切换
+// 读取数据源与建立连接不持有 pool.mu 且受 healthCheckTimeout 限制，避免单个租户阻塞健康检查与 Stats
+func handleFailover(pool *tenantPool, policy *FailoverPolicy, pingErr error) {
+	pool.mu.Lock()
+	if pool.backup {
+		if pingErr != nil {
+			pool.mu.Unlock()
+			// 备库同样异常，移除缓存，下次请求重新创建
+			soelog.Logger.Warn(fmt.Sprintf("租户[%s]备用数据源健康检查失败，将移除缓存: %v", pool.tenantID, pingErr))
+			removeAndCloseDB(pool)
+			return
+		}
+		skip := pool.manual || !policy.FailBack || time.Since(pool.switchedAt) < policy.CoolDown
+		pool.mu.Unlock()
+		if !skip {
+			failBack(pool, policy)
+		}
+		return
+	}
+
+	if pingErr == nil {
+		pool.failures = 0
+		pool.mu.Unlock()
+		return
+	}
+	pool.failures++
+	soelog.Logger.Warn(fmt.Sprintf("租户[%s]主数据源健康检查失败(%d/%d): %v", pool.tenantID, pool.failures, thresholdOf(policy.FailureThreshold), pingErr))
+	skip := pool.failures < thresholdOf(policy.FailureThreshold) ||
+		!pool.switchedAt.IsZero() && time.Since(pool.switchedAt) < policy.CoolDown
+	pool.mu.Unlock()
+	if !skip {
+		failOver(pool, pingErr)
+	}
+}
+
+// failOver 切换到备用数据源，新连接池计入连接预算
+func failOver(pool *tenantPool, pingErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	back, err := loadBackDataSource(ctx, pool.tenantID, pool.provider)
+	if err != nil || back == nil {
+		// 未配置备库，保持原有行为：移除缓存，下次请求重新创建
+		soelog.Logger.Warn(fmt.Sprintf("租户[%s]未配置可用的备用数据源，将移除缓存", pool.tenantID))
+		removeAndCloseDB(pool)
+		return
+	}
+	backDb, release, err := openReserved(ctx, pool, *back)
+	if err != nil {
+		soelog.Logger.Error(fmt.Sprintf("租户[%s]切换备用数据源失败: %v", pool.tenantID, err))
+		return
+	}
+	defer release()
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	// 建立连接期间连接池可能已被回收、移除或切换
+	if pool.backup || !pool.cached() {
+		closeDB(backDb)
+		return
+	}
+	pool.swap(backDb, back.Version, true)
+	emitFailoverEvent(FailoverEvent{TenantID: pool.tenantID, ToBackup: true, Reason: pingErr.Error(), Time: pool.switchedAt})
+}
+
+// failBack 探测主库（单个连接，不创建连接池），连续成功达到阈值后创建主库连接池并切回
+func failBack(pool *tenantPool, policy *FailoverPolicy) {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	tenantDataSource, err := getDataSource(ctx, pool.provider, pool.tenantID)
+	if err == nil {
+		err = probeDB(ctx, tenantDataSource, pool.opt)
+	}
+
+	pool.mu.Lock()
+	if err != nil {
+		pool.recovers = 0
+		pool.mu.Unlock()
+		return
+	}
+	pool.recovers++
+	ready := pool.recovers >= thresholdOf(policy.RecoverThreshold)
+	pool.mu.Unlock()
+	if !ready {
+		return
+	}
+
+	primary, release, err := openReserved(ctx, pool, tenantDataSource)
+	if err != nil {
+		soelog.Logger.Error(fmt.Sprintf("租户[%s]切回主数据源失败: %v", pool.tenantID, err))
+		return
+	}
+	defer release()
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if !pool.backup || !pool.cached() {
+		closeDB(primary)
+		return
+	}
+	pool.swap(primary, tenantDataSource.Version, false)
+	emitFailoverEvent(FailoverEvent{TenantID: pool.tenantID, ToBackup: false, Reason: "主数据源健康检查恢复", Time: pool.switchedAt})
+}
+
+// cached 连接池是否仍在缓存中（未被回收或替换）
+func (p *tenantPool) cached() bool {
+	value, ok := dbMap.Load(p.tenantID)
+	return ok && value == p
+}
+
+// swap 替换连接池并延迟关闭旧连接池（调用方需持有 pool.mu）
+func (p *tenantPool) swap(db *gorm.DB, version int, backup bool) {
+	old := p.db.Swap(db)
+	p.version = version
+	p.backup = backup
+	p.failures = 0
+	p.recovers = 0
+	p.switchedAt = time.Now()
+	if old != nil {
+		drainAndClose(old)
+	}
+}
+
+func thresholdOf(threshold int) int {
+	if threshold <= 0 {
+		return 3
+	}
+	return threshold
+}
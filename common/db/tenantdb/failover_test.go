@@ -0,0 +1,174 @@
+package tenantdb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDriver 按连接串（主机名）模拟数据库宕机的 database/sql 驱动
+type fakeDriver struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	opens atomic.Int32 // openDB 创建的连接池数
+}
+
+func (d *fakeDriver) setDown(name string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down[name] = down
+}
+
+func (d *fakeDriver) isDown(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.down[name]
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if d.isDown(name) {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeConn{d: d, name: name}, nil
+}
+
+type fakeConn struct {
+	d    *fakeDriver
+	name string
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (c *fakeConn) Ping(context.Context) error {
+	if c.d.isDown(c.name) {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+var (
+	fakeDB       = &fakeDriver{down: map[string]bool{}}
+	fakeRegister sync.Once
+)
+
+// useFakeDB 使用 fakeDB 代替真实数据库打开数据源（连接串为数据源 URL）
+func useFakeDB(t *testing.T) *fakeDriver {
+	t.Helper()
+	fakeRegister.Do(func() { sql.Register("tenantdb-fake", fakeDB) })
+	openDB = func(ctx context.Context, tenantDataSource TenantDataSource, opt *OptSQL) (*gorm.DB, error) {
+		fakeDB.opens.Add(1)
+		sqlDB, err := sql.Open("tenantdb-fake", tenantDataSource.URL)
+		if err != nil {
+			return nil, err
+		}
+		setPoolSize(sqlDB, tenantDataSource)
+		if err = sqlDB.PingContext(ctx); err != nil {
+			_ = sqlDB.Close()
+			return nil, err
+		}
+		return gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Discard})
+	}
+	probeDB = func(ctx context.Context, tenantDataSource TenantDataSource, opt *OptSQL) error {
+		sqlDB, err := sql.Open("tenantdb-fake", tenantDataSource.URL)
+		if err != nil {
+			return err
+		}
+		defer sqlDB.Close()
+		return sqlDB.PingContext(ctx)
+	}
+	t.Cleanup(func() {
+		openDB = openDataSource
+		probeDB = probeDataSource
+	})
+	return fakeDB
+}
+
+func TestFailover(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	SetAlertSink(nil)
+	defer SetAlertSink(LogSink{})
+	d := useFakeDB(t)
+
+	provider := NewStaticProvider([]DataSourceConfig{{
+		TenantID: 9001, Version: 1, URL: "failover-primary",
+		Back: &DataSourceConfig{Version: 2, URL: "failover-backup"},
+	}})
+	opt := &OptSQL{}
+	primary, err := openDB(context.Background(), TenantDataSource{URL: "failover-primary"}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := &tenantPool{tenantID: "9001", provider: provider, opt: opt, version: 1}
+	pool.db.Store(primary)
+	dbMap.Store(pool.tenantID, pool)
+	defer dbMap.Delete(pool.tenantID)
+	policy := &FailoverPolicy{FailureThreshold: 2, FailBack: true, RecoverThreshold: 2}
+
+	// 主库连续失败达到阈值后切换到备库
+	d.setDown("failover-primary", true)
+	pingErr := errors.New("connection refused")
+	handleFailover(pool, policy, pingErr)
+	if stats := pool.stats(); stats.Backup || stats.Failures != 1 {
+		t.Fatalf("未达到阈值不应切换: %+v", stats)
+	}
+	handleFailover(pool, policy, pingErr)
+	if stats := pool.stats(); !stats.Backup || stats.Version != 2 || stats.Failures != 0 || pool.db.Load() == primary {
+		t.Fatalf("应切换到备库: %+v", stats)
+	}
+
+	// 主库未恢复时不切回
+	handleFailover(pool, policy, nil)
+	if stats := pool.stats(); !stats.Backup || stats.Recovers != 0 {
+		t.Fatalf("主库未恢复不应切回: %+v", stats)
+	}
+
+	// 主库连续恢复达到阈值后切回，未达到阈值时只探测不创建连接池
+	d.setDown("failover-primary", false)
+	opens := d.opens.Load()
+	handleFailover(pool, policy, nil)
+	if stats := pool.stats(); !stats.Backup || stats.Recovers != 1 || d.opens.Load() != opens {
+		t.Fatalf("未达到阈值不应切回: %+v", stats)
+	}
+	handleFailover(pool, policy, nil)
+	if stats := pool.stats(); stats.Backup || stats.Version != 1 || stats.Recovers != 0 {
+		t.Fatalf("应切回主库: %+v", stats)
+	}
+
+	// 冷却期内不再切换
+	policy.CoolDown = time.Hour
+	handleFailover(pool, policy, pingErr)
+	handleFailover(pool, policy, pingErr)
+	if stats := pool.stats(); stats.Backup {
+		t.Fatalf("冷却期内不应切换: %+v", stats)
+	}
+
+	// 备库超过连接预算时不切换
+	policy.CoolDown = 0
+	SetPoolLimit(PoolLimitConfig{MaxTotalConns: 15})
+	handleFailover(pool, policy, pingErr)
+	handleFailover(pool, policy, pingErr)
+	SetPoolLimit(PoolLimitConfig{})
+	if stats := pool.stats(); stats.Backup || !pool.cached() || reservedConns != 0 {
+		t.Fatalf("超过连接预算不应切换: %+v, 预留 %d", stats, reservedConns)
+	}
+
+	// 切换期间连接池已被回收，不替换连接
+	dbMap.Delete(pool.tenantID)
+	current := pool.db.Load()
+	handleFailover(pool, policy, pingErr)
+	if stats := pool.stats(); stats.Backup || pool.db.Load() != current {
+		t.Fatalf("已回收的连接池不应切换: %+v", stats)
+	}
+}
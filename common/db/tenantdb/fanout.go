@@ -0,0 +1,190 @@
+package tenantdb
+
+/**
+  跨租户批量执行：按并发上限在多个租户上执行同一函数（如夜间任务），汇总各租户结果与错误
+    results, err := tenantdb.FanOut(ctx, tenantIDs, func(ctx context.Context, tenantID string, db *gorm.DB) (int64, error) {
+        var count int64
+        return count, db.Model(&Order{}).Count(&count).Error
+    }, tenantdb.FanOutOption{Concurrency: 20, TenantTimeout: time.Minute})
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/tools/ants"
+	"gorm.io/gorm"
+)
+
+// FanOutOption 跨租户执行配置
+type FanOutOption struct {
+	Concurrency   int                           // 最大并发租户数（默认10）
+	TenantTimeout time.Duration                 // 单个租户超时时间（含获取数据源），0 表示不限制
+	DBOptions     []Option                      // 获取数据源参数（WithProvider、WithApplicationName 等）
+	OnProgress    func(progress FanOutProgress) // 进度回调，每个租户执行完成后调用（串行调用）
+	KeepPools     bool                          // 保留执行期间新建的连接池，默认执行完成后回收（期间被其他请求使用的保留）
+}
+
+// FanOutProgress 执行进度
+type FanOutProgress struct {
+	TenantID string // 刚完成的租户
+	Err      error  // 该租户的执行错误
+	Done     int    // 已完成租户数
+	Failed   int    // 失败租户数
+	Total    int    // 租户总数
+}
+
+// TenantResult 单个租户的执行结果
+type TenantResult[T any] struct {
+	TenantID string
+	Value    T
+	Err      error
+	Duration time.Duration
+}
+
+// FanOutError 部分租户执行失败
+type FanOutError struct {
+	Errors map[string]error // tenantID -> 错误
+	Total  int
+}
+
+func (e *FanOutError) Error() string {
+	tenantIDs := make([]string, 0, len(e.Errors))
+	for tenantID := range e.Errors {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+	msgs := make([]string, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		msgs = append(msgs, fmt.Sprintf("租户[%s]: %v", tenantID, e.Errors[tenantID]))
+	}
+	return fmt.Sprintf("%d/%d 个租户执行失败：%s", len(e.Errors), e.Total, strings.Join(msgs, "；"))
+}
+
+func (e *FanOutError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// FanOut 在指定租户上并发执行 fn，结果按 tenantIDs 顺序返回；部分租户失败时返回 *FanOutError
+func FanOut[T any](ctx context.Context, tenantIDs []string, fn func(ctx context.Context, tenantID string, db *gorm.DB) (T, error), opt FanOutOption) ([]TenantResult[T], error) {
+	results := make([]TenantResult[T], len(tenantIDs))
+	if len(tenantIDs) == 0 {
+		return results, nil
+	}
+	concurrency := opt.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	if concurrency > len(tenantIDs) {
+		concurrency = len(tenantIDs)
+	}
+	pool, err := ants.New("tenantdb-fanout", concurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Release()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		done     int
+		failures = map[string]error{}
+	)
+	finish := func(i int, result TenantResult[T]) {
+		results[i] = result
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if result.Err != nil {
+			failures[result.TenantID] = result.Err
+		}
+		if opt.OnProgress != nil {
+			opt.OnProgress(FanOutProgress{TenantID: result.TenantID, Err: result.Err, Done: done, Failed: len(failures), Total: len(tenantIDs)})
+		}
+	}
+	for i, tenantID := range tenantIDs {
+		wg.Add(1)
+		err = pool.Submit(func() {
+			defer wg.Done()
+			finish(i, runTenant(ctx, tenantID, fn, opt))
+		})
+		if err != nil {
+			wg.Done()
+			finish(i, TenantResult[T]{TenantID: tenantID, Err: err})
+		}
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		return results, &FanOutError{Errors: failures, Total: len(tenantIDs)}
+	}
+	return results, nil
+}
+
+// FanOutAll 在数据源提供者的所有租户上执行 fn（提供者需实现 TenantLister）
+func FanOutAll[T any](ctx context.Context, fn func(ctx context.Context, tenantID string, db *gorm.DB) (T, error), opt FanOutOption) ([]TenantResult[T], error) {
+	o, err := newOptions(opt.DBOptions)
+	if err != nil {
+		return nil, err
+	}
+	lister, ok := o.provider.(TenantLister)
+	if !ok {
+		return nil, errors.New("数据源提供者不支持列出租户！")
+	}
+	tenantIDs, err := lister.ListTenants(ctx)
+	if err != nil {
+		return nil, errors.New("获取租户列表失败:" + err.Error())
+	}
+	return FanOut(ctx, tenantIDs, fn, opt)
+}
+
+// runTenant 在单个租户上执行 fn，执行前未缓存的连接池在执行后回收
+func runTenant[T any](ctx context.Context, tenantID string, fn func(ctx context.Context, tenantID string, db *gorm.DB) (T, error), opt FanOutOption) (result TenantResult[T]) {
+	result.TenantID = tenantID
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("执行异常: %v", r)
+		}
+		result.Duration = time.Since(start)
+	}()
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return
+	}
+	if opt.TenantTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.TenantTimeout)
+		defer cancel()
+	}
+
+	_, cached := dbMap.Load(tenantID)
+	db, err := GetDB(ctx, tenantID, opt.DBOptions...)
+	if err != nil {
+		result.Err = err
+		return
+	}
+	if !cached && !opt.KeepPools {
+		// 只回收本次创建的连接池；执行期间被其他请求使用（或已被替换）的不回收
+		if value, ok := dbMap.Load(tenantID); ok && value.(*tenantPool).db.Load() == db {
+			created := value.(*tenantPool)
+			lastUsed := created.lastUsed.Load()
+			defer func() {
+				if created.lastUsed.Load() == lastUsed {
+					evictPool(created)
+				}
+			}()
+		}
+	}
+	result.Value, result.Err = fn(ctx, tenantID, db.WithContext(ctx))
+	return
+}
@@ -0,0 +1,140 @@
+package tenantdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestFanOut(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	defer ClearUnavailable("")
+
+	provider := NewStaticProvider([]DataSourceConfig{{TenantID: 1001}, {TenantID: 1002}})
+	var progress []FanOutProgress
+	results, err := FanOutAll(context.Background(), func(ctx context.Context, tenantID string, db *gorm.DB) (int, error) {
+		return 1, nil
+	}, FanOutOption{
+		Concurrency: 2,
+		DBOptions:   []Option{WithProvider(provider)},
+		OnProgress: func(p FanOutProgress) {
+			progress = append(progress, p)
+		},
+	})
+
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != 2 || fanOutErr.Total != 2 {
+		t.Fatalf("期望两个租户均失败（数据源地址为空），实际 %v", err)
+	}
+	if len(results) != 2 || results[0].TenantID != "1001" || results[1].TenantID != "1002" || results[0].Err == nil {
+		t.Errorf("结果顺序错误: %+v", results)
+	}
+	if len(progress) != 2 || progress[1].Done != 2 || progress[1].Failed != 2 {
+		t.Errorf("进度回调错误: %+v", progress)
+	}
+
+	if _, err = FanOutAll(context.Background(), func(ctx context.Context, tenantID string, db *gorm.DB) (int, error) {
+		return 0, nil
+	}, FanOutOption{DBOptions: []Option{WithProvider(NewHTTPProvider(HTTPProviderOption{}))}}); err == nil {
+		t.Error("提供者不支持列出租户时应返回错误")
+	}
+}
+
+func TestFanOutFakeDB(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	useFakeDB(t)
+	tenantIDs := []string{"9601", "9602", "9603", "9604"}
+	var configs []DataSourceConfig
+	for i, id := range tenantIDs {
+		configs = append(configs, DataSourceConfig{TenantID: 9601 + i, URL: "fanout-" + id})
+	}
+	provider := NewStaticProvider(configs)
+	opt := FanOutOption{Concurrency: 2, DBOptions: []Option{WithProvider(provider)}}
+	ctx := context.Background()
+	t.Cleanup(func() {
+		for _, id := range tenantIDs {
+			if pool, ok := dbMap.LoadAndDelete(id); ok {
+				closeDB(pool.(*tenantPool).db.Load())
+			}
+		}
+	})
+	cached := func(id string) bool {
+		_, ok := dbMap.Load(id)
+		return ok
+	}
+
+	// 9601 执行前已缓存，执行后保留
+	if _, err := GetDB(ctx, "9601", opt.DBOptions...); err != nil {
+		t.Fatal(err)
+	}
+	var running, maxRunning atomic.Int32
+	results, err := FanOut(ctx, tenantIDs, func(ctx context.Context, tenantID string, db *gorm.DB) (string, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(30 * time.Millisecond)
+		return "ok-" + tenantID, nil
+	}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if result.TenantID != tenantIDs[i] || result.Value != "ok-"+tenantIDs[i] || result.Err != nil {
+			t.Fatalf("结果 %d = %+v", i, result)
+		}
+	}
+	if maxRunning.Load() != 2 {
+		t.Fatalf("最大并发 = %d, want 2", maxRunning.Load())
+	}
+	if !cached("9601") || cached("9602") || cached("9603") || cached("9604") {
+		t.Fatal("应只回收执行期间新建的连接池")
+	}
+
+	// 超时与 panic 按租户记录错误
+	opt.TenantTimeout = 50 * time.Millisecond
+	results, err = FanOut(ctx, []string{"9602", "9603"}, func(ctx context.Context, tenantID string, db *gorm.DB) (string, error) {
+		if tenantID == "9603" {
+			panic("空指针")
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}, opt)
+	var fanOutErr *FanOutError
+	if !errors.As(err, &fanOutErr) || len(fanOutErr.Errors) != 2 {
+		t.Fatalf("期望两个租户均失败，实际 %v", err)
+	}
+	if !errors.Is(results[0].Err, context.DeadlineExceeded) || results[1].Err == nil || !strings.Contains(results[1].Err.Error(), "空指针") {
+		t.Fatalf("超时/异常结果 = %+v", results)
+	}
+
+	// 执行期间被其他请求使用的连接池不回收；KeepPools 时保留
+	opt.TenantTimeout = 0
+	if _, err = FanOut(ctx, []string{"9602"}, func(ctx context.Context, tenantID string, db *gorm.DB) (int, error) {
+		time.Sleep(time.Millisecond)
+		_, err := GetDB(ctx, tenantID, opt.DBOptions...)
+		return 0, err
+	}, opt); err != nil {
+		t.Fatal(err)
+	}
+	if !cached("9602") {
+		t.Fatal("执行期间被其他请求使用的连接池不应回收")
+	}
+	opt.KeepPools = true
+	if _, err = FanOut(ctx, []string{"9603"}, func(ctx context.Context, tenantID string, db *gorm.DB) (int, error) {
+		return 0, nil
+	}, opt); err != nil || !cached("9603") {
+		t.Fatalf("KeepPools 应保留连接池: %v", err)
+	}
+}
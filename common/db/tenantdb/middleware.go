@@ -0,0 +1,182 @@
+package tenantdb
+
+/**
+  gin 中间件：识别请求租户（请求头 tenantId、JWT 登录信息、门店编码），获取租户数据源并注入请求上下文
+  携带 JWT 时请求头租户号须与登录租户一致，否则返回 403
+    router.Use(tenantdb.Middleware(tenantdb.MiddlewareConfig{ServiceName: "crm"}))
+    db := tenantdb.FromContext(c)
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/soedev/soelib/common/soejwt"
+	"github.com/soedev/soelib/common/soelog"
+	"github.com/soedev/soelib/net/app"
+	"gorm.io/gorm"
+)
+
+// ErrTenantNotResolved 请求中无法识别租户
+var ErrTenantNotResolved = errors.New("缺少租户信息")
+
+// ErrTenantMismatch 请求头租户号与登录租户不一致
+var ErrTenantMismatch = errors.New("租户信息与登录信息不一致")
+
+type (
+	tenantDBKey struct{}
+	tenantIDKey struct{}
+)
+
+// MiddlewareConfig 中间件配置
+type MiddlewareConfig struct {
+	ServiceName    string        // 服务名称（错误上报使用）
+	TenantHeader   string        // 租户号请求头，默认 tenantId
+	ShopCodeHeader string        // 门店编码请求头，默认 shopCode
+	TokenHeader    string        // JWT 请求头，默认 Authorization
+	Timeout        time.Duration // 获取数据源超时时间，默认10秒
+	Options        []Option      // 获取数据源参数（WithProvider、WithApplicationName 等）
+	Optional       bool          // 无法识别租户时继续处理（不注入数据源），默认返回 400
+}
+
+func (c *MiddlewareConfig) setDefaults() {
+	if c.TenantHeader == "" {
+		c.TenantHeader = "tenantId"
+	}
+	if c.ShopCodeHeader == "" {
+		c.ShopCodeHeader = "shopCode"
+	}
+	if c.TokenHeader == "" {
+		c.TokenHeader = "Authorization"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+}
+
+// Middleware 识别请求租户并注入租户数据源，处理函数通过 FromContext 取得
+func Middleware(config MiddlewareConfig) gin.HandlerFunc {
+	config.setDefaults()
+	return func(c *gin.Context) {
+		tenantID, shopCode, err := resolveTenant(c, &config)
+		appG := app.Gin{C: c, ServiceName: config.ServiceName, TenantID: tenantID, ShopCode: shopCode}
+		if err != nil {
+			appG.Error = err
+			httpCode := http.StatusUnauthorized
+			if errors.Is(err, ErrTenantMismatch) {
+				httpCode = http.StatusForbidden
+			}
+			appG.ResponseError(httpCode, err.Error())
+			c.Abort()
+			return
+		}
+		// 未识别租户号时按门店编码查询数据源
+		key := tenantID
+		if key == "" {
+			key = shopCode
+		}
+		if key == "" {
+			if config.Optional {
+				c.Next()
+				return
+			}
+			appG.Error = ErrTenantNotResolved
+			appG.ResponseError(http.StatusBadRequest, ErrTenantNotResolved.Error())
+			c.Abort()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), config.Timeout)
+		db, err := GetDB(ctx, key, config.Options...)
+		cancel()
+		if err != nil {
+			appG.Error = err
+			httpCode, msg := tenantErrorResponse(c, key, err)
+			appG.ResponseError(httpCode, msg)
+			c.Abort()
+			return
+		}
+
+		reqCtx := c.Request.Context()
+		reqCtx = context.WithValue(reqCtx, tenantIDKey{}, key)
+		reqCtx = context.WithValue(reqCtx, tenantDBKey{}, db.WithContext(reqCtx))
+		c.Request = c.Request.WithContext(reqCtx)
+		c.Set("tenantId", key)
+		c.Next()
+	}
+}
+
+// resolveTenant 依次从请求头、JWT 登录信息中识别租户号与门店编码
+// 携带 JWT 时校验令牌，请求头租户号与登录租户不一致时返回 ErrTenantMismatch
+func resolveTenant(c *gin.Context, config *MiddlewareConfig) (tenantID, shopCode string, err error) {
+	tenantID = strings.TrimSpace(c.GetHeader(config.TenantHeader))
+	shopCode = strings.TrimSpace(c.GetHeader(config.ShopCodeHeader))
+	token := c.GetHeader(config.TokenHeader)
+	if token == "" {
+		return tenantID, shopCode, nil
+	}
+	info, err := soejwt.ParseJWT(token)
+	if err != nil {
+		// 未提供租户号但已提供门店编码时忽略令牌错误，由门店编码识别租户
+		if tenantID == "" && shopCode != "" {
+			return "", shopCode, nil
+		}
+		return "", "", err
+	}
+	if shopCode == "" {
+		shopCode = info.HoldShopCode
+	}
+	loginTenantID := strings.TrimSpace(info.TenantID)
+	if tenantID == "" {
+		return loginTenantID, shopCode, nil
+	}
+	if loginTenantID != "" && loginTenantID != tenantID {
+		return "", "", ErrTenantMismatch
+	}
+	return tenantID, shopCode, nil
+}
+
+// tenantErrorResponse 数据源获取错误对应的 HTTP 状态码与提示，未知错误只记录日志，不返回驱动错误详情（可能包含数据库地址、用户名）
+func tenantErrorResponse(c *gin.Context, key string, err error) (int, string) {
+	var unavailable *TenantUnavailableError
+	switch {
+	case errors.As(err, &unavailable):
+		if retry := int(time.Until(unavailable.RetryAt).Seconds()) + 1; retry > 0 {
+			c.Header("Retry-After", strconv.Itoa(retry))
+		}
+		return http.StatusServiceUnavailable, "租户数据源暂不可用，请稍后重试"
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "获取租户数据源超时"
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, "请求已取消"
+	case errors.Is(err, ErrConnBudgetExceeded):
+		return http.StatusServiceUnavailable, err.Error()
+	default:
+		soelog.Logger.Error(fmt.Sprintf("租户[%s]获取数据源失败: %v", key, err))
+		return http.StatusInternalServerError, "获取租户数据源失败"
+	}
+}
+
+// FromContext 取得中间件注入的租户数据源，未注入时返回 nil（ctx 可为 *gin.Context 或请求 context）
+func FromContext(ctx context.Context) *gorm.DB {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	db, _ := ctx.Value(tenantDBKey{}).(*gorm.DB)
+	return db
+}
+
+// TenantIDFromContext 取得中间件识别的租户号（或门店编码）
+func TenantIDFromContext(ctx context.Context) string {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		ctx = c.Request.Context()
+	}
+	tenantID, _ := ctx.Value(tenantIDKey{}).(string)
+	return tenantID
+}
@@ -0,0 +1,83 @@
+package tenantdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	defer ClearUnavailable("")
+
+	router := gin.New()
+	router.Use(Middleware(MiddlewareConfig{Options: []Option{WithProvider(NewStaticProvider(nil))}}))
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, TenantIDFromContext(c))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("缺少租户信息，期望 400，实际 %d", w.Code)
+	}
+
+	recordFailure(context.Background(), "1001", errors.New("数据源未配置！"))
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("tenantId", "1001")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("租户不可用，期望 503 及 Retry-After，实际 %d %v", w.Code, w.Header())
+	}
+
+	// 未知错误不返回错误详情
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("tenantId", "1003")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "1003") {
+		t.Errorf("期望 500 且不包含错误详情，实际 %d %s", w.Code, w.Body.String())
+	}
+
+	// 请求头租户号与登录租户不一致
+	// 与 soejwt.ParseJWT 使用相同的签名密钥
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &jwt.StandardClaims{
+		Subject: `{"tenantId":"1001"}`, ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte{195, 12, 44, 161, 231, 43})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tenantID, code := range map[string]int{"1002": http.StatusForbidden, "1001": http.StatusServiceUnavailable, "": http.StatusServiceUnavailable} {
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("tenantId", tenantID)
+		router.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("请求头租户[%s]，期望 %d，实际 %d", tenantID, code, w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	req.Header.Set("tenantId", "1001")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("令牌无效，期望 401，实际 %d", w.Code)
+	}
+
+	if FromContext(context.Background()) != nil {
+		t.Error("未注入时应返回 nil")
+	}
+}
@@ -0,0 +1,208 @@
+package tenantdb
+
+/**
+  GetDB 获取租户数据源（支持 context 与可选参数）：
+    db, err := tenantdb.GetDB(ctx, tenantID, tenantdb.WithApplicationName("crm"), tenantdb.WithTrace(true))
+  ctx 的截止时间作用于数据源查询、建立连接及首次连接检查
+*/
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// defaultApplicationName 默认程序名称
+const defaultApplicationName = "go-service"
+
+// defaultProvider 默认数据源提供者（SetDefaultProvider）
+var defaultProvider atomic.Pointer[TenantDataSourceProvider]
+
+// SetDefaultProvider 设置默认数据源提供者，GetDB 未指定 WithProvider 时使用
+func SetDefaultProvider(provider TenantDataSourceProvider) {
+	defaultProvider.Store(&provider)
+}
+
+// ContextProvider 支持 context 的数据源提供者，查询可随 ctx 取消或超时
+type ContextProvider interface {
+	GetDataSourceContext(ctx context.Context, tenantID string) (TenantDataSource, error)
+	GetBackDataSourceContext(ctx context.Context, tenantID string) (*TenantDataSourceBack, error)
+}
+
+// options GetDB 参数
+type options struct {
+	provider        TenantDataSourceProvider
+	applicationName string
+	enableTrace     bool
+	logLevel        logger.LogLevel
+	config          *gorm.Config
+	failover        *FailoverPolicy
+	backup          bool
+	opt             *OptSQL
+}
+
+// Option GetDB 可选参数
+type Option func(*options)
+
+// WithProvider 指定数据源提供者，未指定时使用 SetDefaultProvider 设置的默认提供者
+func WithProvider(provider TenantDataSourceProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithApplicationName 程序名称（数据库连接的 application_name），默认 go-service
+func WithApplicationName(name string) Option {
+	return func(o *options) {
+		o.applicationName = name
+	}
+}
+
+// WithTrace 启用链路
+func WithTrace(enable bool) Option {
+	return func(o *options) {
+		o.enableTrace = enable
+	}
+}
+
+// WithLogLevel 日志级别，默认 logger.Error
+func WithLogLevel(level logger.LogLevel) Option {
+	return func(o *options) {
+		o.logLevel = level
+	}
+}
+
+// WithGormConfig gorm 配置，未设置 Logger 时按 WithLogLevel 使用默认日志
+func WithGormConfig(config gorm.Config) Option {
+	return func(o *options) {
+		o.config = &config
+	}
+}
+
+// WithFailover 主备自动切换策略，为空时使用全局策略（SetFailoverPolicy）
+func WithFailover(policy *FailoverPolicy) Option {
+	return func(o *options) {
+		o.failover = policy
+	}
+}
+
+// WithBackup 使用备用数据源（已配置并启用时），不自动切回主库
+func WithBackup(enable bool) Option {
+	return func(o *options) {
+		o.backup = enable
+	}
+}
+
+// withOptSQL 直接使用 OptSQL（兼容 GetDbFromMapWithOpt）
+func withOptSQL(opt *OptSQL) Option {
+	return func(o *options) {
+		o.opt = opt
+	}
+}
+
+// optSQL 生成数据库配置
+func (o *options) optSQL() *OptSQL {
+	if o.opt != nil {
+		return o.opt
+	}
+	opt := &OptSQL{
+		ApplicationName: o.applicationName,
+		EnableTrace:     o.enableTrace,
+		Failover:        o.failover,
+	}
+	if o.config != nil {
+		opt.DBConfig = *o.config
+	}
+	if opt.DBConfig.Logger == nil {
+		opt.DBConfig.Logger = logger.Default.LogMode(o.logLevel)
+	}
+	return opt
+}
+
+// GetDB 获取租户数据源，缓存未命中时查询数据源并创建连接；ctx 的截止时间作用于查询、建立连接及首次连接检查
+func GetDB(ctx context.Context, tenantID string, opts ...Option) (*gorm.DB, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return getDbFromMap(ctx, tenantID, o.provider, o.optSQL(), o.backup)
+}
+
+// newOptions 应用可选参数，未指定提供者时使用默认提供者
+func newOptions(opts []Option) (*options, error) {
+	o := &options{
+		applicationName: defaultApplicationName,
+		logLevel:        logger.Error,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.provider == nil {
+		if provider := defaultProvider.Load(); provider != nil {
+			o.provider = *provider
+		}
+	}
+	if o.provider == nil {
+		return nil, errors.New("未设置租户数据源提供者！")
+	}
+	return o, nil
+}
+
+// argumentOptions 将旧版参数列表{程序名称、启用链路、日志级别}按类型转换为 Option，类型不匹配的参数忽略
+func argumentOptions(args ...interface{}) []Option {
+	var opts []Option
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			opts = append(opts, WithApplicationName(v))
+		case bool:
+			opts = append(opts, WithTrace(v))
+		case logger.LogLevel:
+			opts = append(opts, WithLogLevel(v))
+		}
+	}
+	return opts
+}
+
+// gormConfig 复制 gorm 配置，避免多次 gorm.Open 共用同一配置（插件重复注册）
+func gormConfig(opt *OptSQL) *gorm.Config {
+	config := opt.DBConfig
+	config.Plugins = maps.Clone(config.Plugins)
+	// 连接检查由 openDataSource 使用 ctx 执行
+	config.DisableAutomaticPing = true
+	return &config
+}
+
+// getDataSource 查询主数据源，提供者支持 context 时随 ctx 取消
+func getDataSource(ctx context.Context, provider TenantDataSourceProvider, tenantID string) (TenantDataSource, error) {
+	if err := ctx.Err(); err != nil {
+		return TenantDataSource{}, err
+	}
+	if p, ok := provider.(ContextProvider); ok {
+		tenantDataSource, err := p.GetDataSourceContext(ctx, tenantID)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return tenantDataSource, ctxErr
+		}
+		return tenantDataSource, err
+	}
+	return provider.GetDataSource(tenantID)
+}
+
+// getBackDataSource 查询备用数据源，提供者支持 context 时随 ctx 取消
+func getBackDataSource(ctx context.Context, provider TenantDataSourceProvider, tenantID string) (*TenantDataSourceBack, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p, ok := provider.(ContextProvider); ok {
+		back, err := p.GetBackDataSourceContext(ctx, tenantID)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return back, err
+	}
+	return provider.GetBackDataSource(tenantID)
+}
@@ -0,0 +1,29 @@
+package tenantdb
+
+import (
+	"context"
+	"testing"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestOptions(t *testing.T) {
+	o := &options{applicationName: defaultApplicationName, logLevel: logger.Error}
+	// 旧版参数按类型识别，顺序错误或类型不符时不再 panic
+	for _, opt := range argumentOptions(true, "crm", logger.Info, 1) {
+		opt(o)
+	}
+	if o.applicationName != "crm" || !o.enableTrace || o.logLevel != logger.Info {
+		t.Errorf("参数解析错误: %+v", o)
+	}
+	opt := o.optSQL()
+	if opt.ApplicationName != "crm" || !opt.EnableTrace || opt.DBConfig.Logger == nil {
+		t.Errorf("数据库配置错误: %+v", opt)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, _, err := _getDB(ctx, "1", NewStaticProvider(nil), opt, false); err != context.Canceled {
+		t.Errorf("ctx 已取消，期望 context.Canceled，实际 %v", err)
+	}
+}
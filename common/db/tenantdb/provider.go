@@ -0,0 +1,445 @@
+package tenantdb
+
+/**
+  租户数据源提供者：CRM 数据表、静态配置文件、HTTP 接口、Nacos 配置
+*/
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"github.com/soedev/soelib/tools/nacos"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// TenantDataSourceProvider 租户数据源提供者
+type TenantDataSourceProvider interface {
+	// GetDataSource 根据租户号（或门店编码）获取主数据源
+	GetDataSource(tenantID string) (TenantDataSource, error)
+	// GetBackDataSource 根据租户号（或门店编码）获取备用数据源，未配置时返回 nil
+	GetBackDataSource(tenantID string) (*TenantDataSourceBack, error)
+}
+
+// TenantLister 可列出所有租户的数据源提供者（FanOutAll 使用）
+type TenantLister interface {
+	ListTenants(ctx context.Context) ([]string, error)
+}
+
+// crmProvider 基于 CRM 数据表（crm.tenant_datasource / crm.tenant_datasource_back）的提供者
+type crmProvider struct {
+	db   *gorm.DB
+	tds  *TDSRepository
+	tdsb *TDSBRepository
+}
+
+// NewCRMProvider 创建基于 CRM 数据库的数据源提供者
+func NewCRMProvider(crmDB *gorm.DB) TenantDataSourceProvider {
+	return &crmProvider{
+		db:   crmDB,
+		tds:  NewTDSRepository(crmDB),
+		tdsb: NewTDSBRepository(crmDB),
+	}
+}
+
+func (p *crmProvider) GetDataSource(tenantID string) (TenantDataSource, error) {
+	return p.tds.GetByTenantID(tenantID)
+}
+
+func (p *crmProvider) GetBackDataSource(tenantID string) (*TenantDataSourceBack, error) {
+	return p.tdsb.GetByTenantID(tenantID)
+}
+
+func (p *crmProvider) ListTenants(ctx context.Context) ([]string, error) {
+	var tenantIDs []int
+	err := p.db.WithContext(ctx).Raw(`SELECT DISTINCT tenant_id FROM crm.tenant_datasource ORDER BY tenant_id`).Scan(&tenantIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		result = append(result, strconv.Itoa(tenantID))
+	}
+	return result, nil
+}
+
+func (p *crmProvider) GetDataSourceContext(ctx context.Context, tenantID string) (TenantDataSource, error) {
+	return NewTDSRepository(p.db.WithContext(ctx)).GetByTenantID(tenantID)
+}
+
+func (p *crmProvider) GetBackDataSourceContext(ctx context.Context, tenantID string) (*TenantDataSourceBack, error) {
+	return NewTDSBRepository(p.db.WithContext(ctx)).GetByTenantID(tenantID)
+}
+
+// DataSourceConfig 数据源配置（静态文件、HTTP 接口、Nacos 使用）
+type DataSourceConfig struct {
+	TenantID        int               `json:"tenantId" yaml:"tenantId"`
+	TenantCode      string            `json:"tenantCode" yaml:"tenantCode"`
+	ShopCodes       []string          `json:"shopCodes" yaml:"shopCodes"` // 门店编码，支持按门店编码查找
+	Version         int               `json:"version" yaml:"version"`
+	Name            string            `json:"name" yaml:"name"`
+	URL             string            `json:"url" yaml:"url"`
+	UserName        string            `json:"userName" yaml:"userName"`
+	Password        string            `json:"password" yaml:"password"` // DES 加密后的密码，与 CRM 表保持一致
+	DriverClassname string            `json:"driverClassname" yaml:"driverClassname"`
+	PoolSize        int               `json:"poolSize" yaml:"poolSize"`
+	MaxPoolSize     int               `json:"maxPoolSize" yaml:"maxPoolSize"`
+	ExpMinute       int               `json:"expMinute" yaml:"expMinute"`     // 连接最大生命周期（分钟）
+	ReplicaURLs     []string          `json:"replicaUrls" yaml:"replicaUrls"` // 只读副本 JDBC 地址（可选）
+	Back            *DataSourceConfig `json:"back" yaml:"back"`               // 备用数据源（可选）
+}
+
+// toDataSource 转换为主数据源
+func (c *DataSourceConfig) toDataSource() TenantDataSource {
+	return TenantDataSource{
+		TenantID:        c.TenantID,
+		TenantCode:      c.TenantCode,
+		Version:         c.Version,
+		Name:            c.Name,
+		URL:             c.URL,
+		UserName:        c.UserName,
+		Password:        c.Password,
+		DriverClassname: c.DriverClassname,
+		PoolSize:        c.PoolSize,
+		MaxPoolSize:     c.MaxPoolSize,
+		ExpMinute:       time.Duration(c.ExpMinute),
+		ReplicaURLs:     strings.Join(c.ReplicaURLs, replicaSeparator),
+	}
+}
+
+// toBackDataSource 转换为备用数据源，未配置时返回 nil
+func (c *DataSourceConfig) toBackDataSource() *TenantDataSourceBack {
+	if c.Back == nil {
+		return nil
+	}
+	back := c.Back
+	return &TenantDataSourceBack{
+		TenantID:        c.TenantID,
+		TenantCode:      c.TenantCode,
+		Version:         back.Version,
+		Name:            back.Name,
+		URL:             back.URL,
+		UserName:        back.UserName,
+		Password:        back.Password,
+		DriverClassname: back.DriverClassname,
+		PoolSize:        back.PoolSize,
+		MaxPoolSize:     back.MaxPoolSize,
+		ExpMinute:       time.Duration(back.ExpMinute),
+		Enable:          1,
+	}
+}
+
+// matches 判断配置是否属于该租户号（或门店编码）
+func (c *DataSourceConfig) matches(tenantID string) bool {
+	if strconv.Itoa(c.TenantID) == tenantID || (c.TenantCode != "" && c.TenantCode == tenantID) {
+		return true
+	}
+	for _, code := range c.ShopCodes {
+		if code == tenantID {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDataSourceConfigs 解析数据源配置列表，支持 JSON 与 YAML 格式
+func ParseDataSourceConfigs(content []byte) ([]DataSourceConfig, error) {
+	var configs []DataSourceConfig
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return configs, nil
+	}
+	var err error
+	if content[0] == '[' {
+		err = json.Unmarshal(content, &configs)
+	} else {
+		err = yaml.Unmarshal(content, &configs)
+	}
+	if err != nil {
+		return nil, errors.New("解析数据源配置失败:" + err.Error())
+	}
+	return configs, nil
+}
+
+// StaticProvider 基于静态配置的提供者（配置文件、Nacos 共用）
+type StaticProvider struct {
+	mu        sync.RWMutex
+	configs   []DataSourceConfig
+	listeners map[int]func()
+	nextID    int
+}
+
+// NewStaticProvider 根据配置列表创建提供者
+func NewStaticProvider(configs []DataSourceConfig) *StaticProvider {
+	return &StaticProvider{configs: configs}
+}
+
+// NewFileProvider 根据 JSON/YAML 配置文件创建提供者
+func NewFileProvider(path string) (*StaticProvider, error) {
+	p := &StaticProvider{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("读取数据源配置文件失败:" + err.Error())
+	}
+	if err = p.Load(content); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Load 重新加载配置内容（JSON 或 YAML）
+func (p *StaticProvider) Load(content []byte) error {
+	configs, err := ParseDataSourceConfigs(content)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.configs = configs
+	listeners := make([]func(), 0, len(p.listeners))
+	for _, listener := range p.listeners {
+		listeners = append(listeners, listener)
+	}
+	p.mu.Unlock()
+	for _, listener := range listeners {
+		listener()
+	}
+	return nil
+}
+
+// Watch 实现 VersionWatcher：配置重新加载（如 Nacos 配置变更）后检查所有已缓存租户
+func (p *StaticProvider) Watch(ctx context.Context, onChange func(tenantID string)) error {
+	changed := make(chan struct{}, 1)
+	p.mu.Lock()
+	if p.listeners == nil {
+		p.listeners = make(map[int]func())
+	}
+	id := p.nextID
+	p.nextID++
+	p.listeners[id] = func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.listeners, id)
+		p.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+			onChange("")
+		}
+	}
+}
+
+func (p *StaticProvider) find(tenantID string) (*DataSourceConfig, error) {
+	tenantID = strings.TrimSpace(tenantID)
+	if tenantID == "" {
+		return nil, errors.New("无效的租户号")
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for i := range p.configs {
+		if p.configs[i].matches(tenantID) {
+			config := p.configs[i]
+			return &config, nil
+		}
+	}
+	return nil, nil
+}
+
+func (p *StaticProvider) ListTenants(context.Context) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	tenantIDs := make([]string, 0, len(p.configs))
+	for _, config := range p.configs {
+		// 仅按租户编码或门店编码配置的数据源没有租户号
+		if config.TenantID == 0 {
+			continue
+		}
+		tenantIDs = append(tenantIDs, strconv.Itoa(config.TenantID))
+	}
+	return tenantIDs, nil
+}
+
+func (p *StaticProvider) GetDataSource(tenantID string) (TenantDataSource, error) {
+	config, err := p.find(tenantID)
+	if err != nil {
+		return TenantDataSource{}, err
+	}
+	if config == nil {
+		return TenantDataSource{}, errors.New("数据源未配置！")
+	}
+	return config.toDataSource(), nil
+}
+
+func (p *StaticProvider) GetBackDataSource(tenantID string) (*TenantDataSourceBack, error) {
+	config, err := p.find(tenantID)
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return config.toBackDataSource(), nil
+}
+
+// NewNacosProvider 根据 Nacos 配置（dataId）创建提供者，配置变更时自动重新加载
+func NewNacosProvider(client *nacos.Client, dataID string) (*StaticProvider, error) {
+	if client == nil {
+		return nil, errors.New("nacos 客户端未初始化")
+	}
+	values, err := client.GetValues([]string{dataID})
+	if err != nil {
+		return nil, err
+	}
+	content, ok := values[dataID]
+	if !ok {
+		return nil, fmt.Errorf("nacos 未找到数据源配置：%s", dataID)
+	}
+	p := &StaticProvider{}
+	if err = p.Load([]byte(content)); err != nil {
+		return nil, err
+	}
+	err = client.WatchPrefix([]string{dataID}, func(content, dataID string) {
+		if err := p.Load([]byte(content)); err != nil {
+			soelog.Logger.Error(fmt.Sprintf("nacos 数据源配置[%s]重新加载失败: %v", dataID, err))
+			return
+		}
+		soelog.Logger.Info(fmt.Sprintf("nacos 数据源配置[%s]已重新加载", dataID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// HTTPProviderOption HTTP 提供者配置
+type HTTPProviderOption struct {
+	Endpoint string            // 接口地址，租户号以 tenantId 查询参数传递
+	Header   map[string]string // 请求头（如 Authorization）
+	Timeout  time.Duration     // 超时时间，默认 10 秒
+}
+
+// httpProvider 基于 HTTP 接口的提供者
+type httpProvider struct {
+	endpoint string
+	header   map[string]string
+	client   *http.Client
+}
+
+// httpProviderResponse 接口统一返回格式（与 app.Gin 一致）
+type httpProviderResponse struct {
+	Code *int              `json:"code"`
+	Msg  string            `json:"msg"`
+	Data *DataSourceConfig `json:"data"`
+}
+
+// NewHTTPProvider 创建基于 HTTP 接口的数据源提供者
+func NewHTTPProvider(opt HTTPProviderOption) TenantDataSourceProvider {
+	if opt.Timeout <= 0 {
+		opt.Timeout = 10 * time.Second
+	}
+	return &httpProvider{
+		endpoint: opt.Endpoint,
+		header:   opt.Header,
+		client:   &http.Client{Timeout: opt.Timeout},
+	}
+}
+
+func (p *httpProvider) fetch(ctx context.Context, tenantID string) (*DataSourceConfig, error) {
+	tenantID = strings.TrimSpace(tenantID)
+	if tenantID == "" {
+		return nil, errors.New("无效的租户号")
+	}
+	reqURL, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, errors.New("数据源接口地址错误:" + err.Error())
+	}
+	query := reqURL.Query()
+	query.Set("tenantId", tenantID)
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range p.header {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, errors.New("请求数据源接口失败:" + err.Error())
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求数据源接口失败，状态码：%d，%s", resp.StatusCode, string(body))
+	}
+	var result httpProviderResponse
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, errors.New("解析数据源接口返回失败:" + err.Error())
+	}
+	if result.Code != nil {
+		if *result.Code != 0 && *result.Code != http.StatusOK {
+			return nil, fmt.Errorf("请求数据源接口失败，返回码：%d，%s", *result.Code, result.Msg)
+		}
+		return result.Data, nil
+	}
+	// 兼容直接返回数据源配置的接口
+	var config DataSourceConfig
+	if err = json.Unmarshal(body, &config); err != nil {
+		return nil, errors.New("解析数据源接口返回失败:" + err.Error())
+	}
+	if config.URL == "" {
+		return nil, nil
+	}
+	return &config, nil
+}
+
+func (p *httpProvider) GetDataSource(tenantID string) (TenantDataSource, error) {
+	return p.GetDataSourceContext(context.Background(), tenantID)
+}
+
+func (p *httpProvider) GetBackDataSource(tenantID string) (*TenantDataSourceBack, error) {
+	return p.GetBackDataSourceContext(context.Background(), tenantID)
+}
+
+func (p *httpProvider) GetDataSourceContext(ctx context.Context, tenantID string) (TenantDataSource, error) {
+	config, err := p.fetch(ctx, tenantID)
+	if err != nil {
+		return TenantDataSource{}, err
+	}
+	if config == nil {
+		return TenantDataSource{}, errors.New("数据源未配置！")
+	}
+	return config.toDataSource(), nil
+}
+
+func (p *httpProvider) GetBackDataSourceContext(ctx context.Context, tenantID string) (*TenantDataSourceBack, error) {
+	config, err := p.fetch(ctx, tenantID)
+	if err != nil || config == nil {
+		return nil, err
+	}
+	return config.toBackDataSource(), nil
+}
@@ -0,0 +1,124 @@
+package tenantdb
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStaticProvider(t *testing.T) {
+	jsonContent := `[{"tenantId":600002,"tenantCode":"soe","shopCodes":["S001"],"url":"jdbc:postgresql://127.0.0.1:5432/soedb",
+		"userName":"soe","password":"xxx","driverClassname":"org.postgresql.ds.PGSimpleDataSource","maxPoolSize":20,
+		"back":{"url":"jdbc:postgresql://127.0.0.2:5432/soedb","userName":"soe","password":"xxx","driverClassname":"org.postgresql.ds.PGSimpleDataSource"}}]`
+	yamlContent := `
+- tenantId: 600002
+  tenantCode: soe
+  shopCodes: [S001]
+  url: jdbc:postgresql://127.0.0.1:5432/soedb
+  userName: soe
+  password: xxx
+  driverClassname: org.postgresql.ds.PGSimpleDataSource
+  maxPoolSize: 20
+  back:
+    url: jdbc:postgresql://127.0.0.2:5432/soedb
+    userName: soe
+    password: xxx
+    driverClassname: org.postgresql.ds.PGSimpleDataSource
+`
+	for name, content := range map[string]string{"json": jsonContent, "yaml": yamlContent} {
+		p := NewStaticProvider(nil)
+		if err := p.Load([]byte(content)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, key := range []string{"600002", "soe", "S001"} {
+			ds, err := p.GetDataSource(key)
+			if err != nil {
+				t.Fatalf("%s: 查找[%s]失败: %v", name, key, err)
+			}
+			if ds.URL != "jdbc:postgresql://127.0.0.1:5432/soedb" || ds.UserName != "soe" || ds.MaxPoolSize != 20 {
+				t.Errorf("%s: 数据源解析错误: %+v", name, ds)
+			}
+		}
+		back, err := p.GetBackDataSource("600002")
+		if err != nil || back == nil || back.Enable != 1 || back.URL != "jdbc:postgresql://127.0.0.2:5432/soedb" {
+			t.Errorf("%s: 备用数据源解析错误: %+v, %v", name, back, err)
+		}
+		if _, err := p.GetDataSource("600003"); err == nil {
+			t.Errorf("%s: 未配置的租户应返回错误", name)
+		}
+	}
+}
+
+func TestStaticProviderWatch(t *testing.T) {
+	p := NewStaticProvider(nil)
+	if err := p.Load([]byte(`[{"tenantId":600002,"url":"jdbc:postgresql://127.0.0.1:5432/soedb"},{"tenantCode":"soe","url":"jdbc:postgresql://127.0.0.1:5432/soedb"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if tenants, _ := p.ListTenants(context.Background()); len(tenants) != 1 || tenants[0] != "600002" {
+		t.Fatalf("ListTenants = %v", tenants)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = p.Watch(ctx, func(string) {})
+	}()
+	deadline := time.Now().Add(time.Second)
+	for p.listenerCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if n := p.listenerCount(); n != 0 {
+		t.Fatalf("Watch 结束后仍有 %d 个监听", n)
+	}
+}
+
+func (p *StaticProvider) listenerCount() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.listeners)
+}
+
+func TestHTTPProvider(t *testing.T) {
+	responses := map[string]string{
+		"1": `{"code":200,"msg":"ok","data":{"tenantId":1,"url":"jdbc:postgresql://127.0.0.1:5432/soedb"}}`,
+		"2": `{"code":500,"msg":"服务异常","data":null}`,
+		"3": `{"code":0,"data":null}`,
+		"4": `{"tenantId":4,"url":"jdbc:postgresql://127.0.0.1:5432/soedb"}`,
+		"5": `<html><body>502 Bad Gateway</body></html>`,
+		"6": `{"tenantId":"6","url":"jdbc:postgresql://127.0.0.1:5432/soedb"}`,
+		"7": `{"tenantId":7}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(responses[r.URL.Query().Get("tenantId")]))
+	}))
+	defer server.Close()
+	p := NewHTTPProvider(HTTPProviderOption{Endpoint: server.URL}).(*httpProvider)
+	ctx := context.Background()
+
+	if config, err := p.fetch(ctx, "1"); err != nil || config == nil || config.TenantID != 1 {
+		t.Fatalf("fetch(1) = %+v, %v", config, err)
+	}
+	if _, err := p.fetch(ctx, "2"); err == nil {
+		t.Fatal("返回码异常时应返回错误")
+	}
+	if config, err := p.fetch(ctx, "3"); err != nil || config != nil {
+		t.Fatalf("未配置 fetch(3) = %+v, %v", config, err)
+	}
+	if config, err := p.fetch(ctx, "4"); err != nil || config == nil || config.TenantID != 4 {
+		t.Fatalf("直接返回配置 fetch(4) = %+v, %v", config, err)
+	}
+	// 无法解析的返回不能当作未配置
+	for _, id := range []string{"5", "6"} {
+		if config, err := p.fetch(ctx, id); err == nil {
+			t.Fatalf("无法解析 fetch(%s) = %+v", id, config)
+		}
+	}
+	if config, err := p.fetch(ctx, "7"); err != nil || config != nil {
+		t.Fatalf("url 为空 fetch(7) = %+v, %v", config, err)
+	}
+}
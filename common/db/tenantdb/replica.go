@@ -0,0 +1,175 @@
+package tenantdb
+
+/**
+  租户只读副本路由：读请求发往只读副本，写请求与事务发往主库（基于 gorm dbresolver 插件）
+  只读副本由健康检查循环检测，异常副本自动摘除，全部异常时读请求回落到主库
+*/
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/soedev/soelib/common/soelog"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ForcePrimary 强制使用主库，如读后即查的场景：db.Clauses(tenantdb.ForcePrimary).Find(&list)
+var ForcePrimary = dbresolver.Write
+
+// replicaSeparator 多个只读副本地址的分隔符
+const replicaSeparator = "|"
+
+// replicaMap 连接池对应的只读副本（*gorm.DB -> []*replicaConn）
+var replicaMap sync.Map
+
+// replicaConn 只读副本连接，副本异常时读请求回落到主库
+type replicaConn struct {
+	url     string
+	db      *sql.DB
+	primary *sql.DB
+	healthy atomic.Bool
+}
+
+// target 取得实际执行的连接
+func (c *replicaConn) target() *sql.DB {
+	if c.healthy.Load() {
+		return c.db
+	}
+	return c.primary
+}
+
+func (c *replicaConn) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return c.target().PrepareContext(ctx, query)
+}
+
+func (c *replicaConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.target().ExecContext(ctx, query, args...)
+}
+
+func (c *replicaConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.target().QueryContext(ctx, query, args...)
+}
+
+func (c *replicaConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return c.target().QueryRowContext(ctx, query, args...)
+}
+
+// healthyPolicy 在健康的只读副本中随机选择
+type healthyPolicy struct{}
+
+func (healthyPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	healthy := make([]gorm.ConnPool, 0, len(connPools))
+	for _, connPool := range connPools {
+		if c, ok := connPool.(*replicaConn); !ok || c.healthy.Load() {
+			healthy = append(healthy, connPool)
+		}
+	}
+	if len(healthy) == 0 {
+		return connPools[rand.Intn(len(connPools))]
+	}
+	return healthy[rand.Intn(len(healthy))]
+}
+
+// replicaURLs 取得只读副本地址列表
+func (t TenantDataSource) replicaURLs() []string {
+	var urls []string
+	for _, url := range strings.Split(t.ReplicaURLs, replicaSeparator) {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// useReplicas 为连接注册只读副本，单个副本连接失败时跳过
+func useReplicas(ctx context.Context, sqlDb *gorm.DB, driver string, tenantDataSource TenantDataSource, password string, opt *OptSQL) error {
+	urls := tenantDataSource.replicaURLs()
+	if len(urls) == 0 {
+		return nil
+	}
+	primary, err := sqlDb.DB()
+	if err != nil {
+		return err
+	}
+	var replicas []*replicaConn
+	var dialectors []gorm.Dialector
+	for _, url := range urls {
+		dialector, _, err := dialectorOf(url, tenantDataSource.UserName, password, opt.ApplicationName)
+		if err != nil {
+			soelog.Logger.Warn(fmt.Sprintf("只读副本[%s]配置错误，已跳过: %v", url, err))
+			continue
+		}
+		replicaDb, err := gorm.Open(dialector, &gorm.Config{Logger: opt.DBConfig.Logger, DisableAutomaticPing: true})
+		if err != nil {
+			soelog.Logger.Warn(fmt.Sprintf("只读副本[%s]连接失败，已跳过: %v", url, err))
+			continue
+		}
+		db, err := replicaDb.DB()
+		if err != nil {
+			continue
+		}
+		if err = db.PingContext(ctx); err != nil {
+			_ = db.Close()
+			soelog.Logger.Warn(fmt.Sprintf("只读副本[%s]连接失败，已跳过: %v", url, err))
+			continue
+		}
+		setPoolSize(db, tenantDataSource)
+		replica := &replicaConn{url: url, db: db, primary: primary}
+		replica.healthy.Store(true)
+		replicas = append(replicas, replica)
+		dialectors = append(dialectors, dialectorWithConn(driver, replica))
+	}
+	if len(replicas) == 0 {
+		return nil
+	}
+	err = sqlDb.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   healthyPolicy{},
+	}))
+	if err != nil {
+		for _, replica := range replicas {
+			_ = replica.db.Close()
+		}
+		return err
+	}
+	replicaMap.Store(sqlDb, replicas)
+	return nil
+}
+
+// replicasOf 取得连接注册的只读副本
+func replicasOf(db *gorm.DB) []*replicaConn {
+	if value, ok := replicaMap.Load(db); ok {
+		return value.([]*replicaConn)
+	}
+	return nil
+}
+
+// closeReplicas 关闭连接注册的只读副本
+func closeReplicas(db *gorm.DB) {
+	if value, ok := replicaMap.LoadAndDelete(db); ok {
+		for _, replica := range value.([]*replicaConn) {
+			_ = replica.db.Close()
+		}
+	}
+}
+
+// checkReplicas 健康检查只读副本，状态变化时记录日志
+func checkReplicas(tenantID string, db *gorm.DB) {
+	for _, replica := range replicasOf(db) {
+		err := pingDB(replica.db)
+		healthy := err == nil
+		if replica.healthy.Swap(healthy) != healthy {
+			if healthy {
+				soelog.Logger.Info(fmt.Sprintf("租户[%s]只读副本[%s]已恢复", tenantID, replica.url))
+			} else {
+				soelog.Logger.Warn(fmt.Sprintf("租户[%s]只读副本[%s]健康检查失败，读请求回落到主库: %v", tenantID, replica.url, err))
+			}
+		}
+	}
+}
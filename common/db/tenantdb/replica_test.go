@@ -0,0 +1,27 @@
+package tenantdb
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestHealthyPolicy(t *testing.T) {
+	ds := TenantDataSource{ReplicaURLs: " jdbc:postgresql://10.0.0.1:5432/soedb | jdbc:postgresql://10.0.0.2:5432/soedb |"}
+	if urls := ds.replicaURLs(); len(urls) != 2 || urls[1] != "jdbc:postgresql://10.0.0.2:5432/soedb" {
+		t.Fatalf("只读副本地址解析错误: %v", urls)
+	}
+
+	healthy, broken := &replicaConn{url: "a"}, &replicaConn{url: "b"}
+	healthy.healthy.Store(true)
+	for i := 0; i < 20; i++ {
+		if c := (healthyPolicy{}).Resolve([]gorm.ConnPool{broken, healthy}); c != healthy {
+			t.Fatalf("应只选择健康的只读副本")
+		}
+	}
+	// 全部异常时仍返回其中之一（由副本连接回落到主库）
+	healthy.healthy.Store(false)
+	if c := (healthyPolicy{}).Resolve([]gorm.ConnPool{broken, healthy}); c == nil {
+		t.Fatalf("全部异常时应返回任一副本")
+	}
+}
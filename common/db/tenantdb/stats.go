@@ -0,0 +1,102 @@
+package tenantdb
+
+/**
+  租户连接池统计：连接池状态、最近一次健康检查结果、主备切换状态
+*/
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// PoolStats 租户连接池统计
+type PoolStats struct {
+	TenantID     string         `json:"tenantId"`
+	Version      int            `json:"version"`      // 当前使用的数据源版本
+	Backup       bool           `json:"backup"`       // 是否使用备用数据源
+	Manual       bool           `json:"manual"`       // 是否调用方指定使用备用数据源
+	Failures     int            `json:"failures"`     // 主库连续健康检查失败次数
+	Recovers     int            `json:"recovers"`     // 使用备库期间主库连续恢复次数
+	SwitchedAt   time.Time      `json:"switchedAt"`   // 最近一次主备切换时间
+	LastUsed     time.Time      `json:"lastUsed"`     // 最近使用时间
+	LastCheck    time.Time      `json:"lastCheck"`    // 最近一次健康检查时间
+	LastCheckErr string         `json:"lastCheckErr"` // 最近一次健康检查错误，为空表示正常
+	DB           sql.DBStats    `json:"db"`
+	Replicas     []ReplicaStats `json:"replicas,omitempty"`
+}
+
+// ReplicaStats 只读副本统计
+type ReplicaStats struct {
+	URL     string      `json:"url"`
+	Healthy bool        `json:"healthy"`
+	DB      sql.DBStats `json:"db"`
+}
+
+// healthResult 健康检查结果
+type healthResult struct {
+	time time.Time
+	err  error
+}
+
+// recordHealth 记录健康检查结果
+func (p *tenantPool) recordHealth(err error) {
+	p.health.Store(&healthResult{time: time.Now(), err: err})
+}
+
+// Stats 返回所有已缓存租户的连接池统计（按租户号排序）
+func Stats() []PoolStats {
+	pools, _ := listPools()
+	result := make([]PoolStats, 0, len(pools))
+	for _, pool := range pools {
+		result = append(result, pool.stats())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TenantID < result[j].TenantID
+	})
+	return result
+}
+
+// TenantStats 返回指定租户的连接池统计，未缓存时返回 false
+func TenantStats(tenantID string) (PoolStats, bool) {
+	value, ok := dbMap.Load(tenantID)
+	if !ok {
+		return PoolStats{}, false
+	}
+	return value.(*tenantPool).stats(), true
+}
+
+func (p *tenantPool) stats() PoolStats {
+	p.mu.Lock()
+	stats := PoolStats{
+		TenantID:   p.tenantID,
+		Version:    p.version,
+		Backup:     p.backup,
+		Manual:     p.manual,
+		Failures:   p.failures,
+		Recovers:   p.recovers,
+		SwitchedAt: p.switchedAt,
+	}
+	p.mu.Unlock()
+	if lastUsed := p.lastUsed.Load(); lastUsed > 0 {
+		stats.LastUsed = time.Unix(0, lastUsed)
+	}
+	if health := p.health.Load(); health != nil {
+		stats.LastCheck = health.time
+		if health.err != nil {
+			stats.LastCheckErr = health.err.Error()
+		}
+	}
+	db := p.db.Load()
+	if sqlDB, err := db.DB(); err == nil {
+		stats.DB = sqlDB.Stats()
+	}
+	for _, replica := range replicasOf(db) {
+		stats.Replicas = append(stats.Replicas, ReplicaStats{
+			URL:     replica.url,
+			Healthy: replica.healthy.Load(),
+			DB:      replica.db.Stats(),
+		})
+	}
+	return stats
+}
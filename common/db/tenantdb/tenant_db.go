@@ -5,22 +5,19 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/soedev/soelib/common/des"
 	"github.com/soedev/soelib/common/keylock"
 	"github.com/soedev/soelib/common/soelog"
 	"github.com/soedev/soelib/common/utils"
-	"gorm.io/driver/postgres"
-	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 	"gorm.io/plugin/opentelemetry/tracing"
 )
 
-// dbMap 数据源缓存列表
+// dbMap 数据源缓存列表（tenantID -> *tenantPool）
 var dbMap sync.Map
 
 // 健康检查配置
@@ -32,61 +29,113 @@ var (
 	healthCheckerCancel context.CancelFunc
 )
 
+// openDB 打开数据源连接（测试时替换为假连接）
+var openDB = openDataSource
+
+// probeDB 检查数据源连通性，不创建连接池（测试时替换为假连接）
+var probeDB = probeDataSource
+
+// drainDelay 连接池被替换或回收后延迟关闭的时间，让已取得旧连接池的请求执行完毕
+var drainDelay = 30 * time.Second
+
 type OptSQL struct {
 	DBConfig        gorm.Config
 	EnableTrace     bool
 	ApplicationName string
+	Failover        *FailoverPolicy // 主备自动切换策略，为空时使用全局策略（SetFailoverPolicy）
 }
 
-// 获取租户数据源统一方法：  tenantID（租户编号）、crmDB（获取配置连接源）、optSQL（数据库配置参数）、enable（是否启用备用数据源）
-func _getDB(tenantID string, crmDB *gorm.DB, opt *OptSQL, enable bool) (sqlDb *gorm.DB, err error) {
-	var tenantDataSource TenantDataSource
-	isNew := enable
+// tenantPool 租户连接池缓存项
+type tenantPool struct {
+	tenantID string
+	provider TenantDataSourceProvider
+	opt      *OptSQL
+	db       atomic.Pointer[gorm.DB]
+	lastUsed atomic.Int64                 // 最近使用时间（UnixNano），用于空闲回收
+	health   atomic.Pointer[healthResult] // 最近一次健康检查结果
+	waits    atomic.Int64                 // 上次健康检查时的累计等待连接次数
+
+	mu         sync.Mutex
+	version    int       // 当前使用的数据源版本（TenantDataSource.Version）
+	backup     bool      // 当前是否使用备用数据源
+	manual     bool      // 调用方指定使用备用数据源（不自动切回主库）
+	failures   int       // 主库连续健康检查失败次数
+	recovers   int       // 切换到备库后，主库连续健康检查成功次数
+	switchedAt time.Time // 最近一次主备切换时间
+}
+
+// 获取租户数据源统一方法：  tenantID（租户编号）、provider（数据源提供者）、optSQL（数据库配置参数）、enable（是否启用备用数据源）
+// 返回连接、使用的数据源配置及是否使用了备用数据源
+func _getDB(ctx context.Context, tenantID string, provider TenantDataSourceProvider, opt *OptSQL, enable bool) (sqlDb *gorm.DB, tenantDataSource TenantDataSource, isBack bool, err error) {
+	if provider == nil {
+		return nil, tenantDataSource, false, errors.New("未设置租户数据源提供者！")
+	}
 	if enable {
-		repository := NewTDSBRepository(crmDB)
-		tenantDataSourceBack, err := repository.GetByTenantID(tenantID)
+		back, err := loadBackDataSource(ctx, tenantID, provider)
 		if err != nil {
-			return nil, err
+			return nil, tenantDataSource, false, err
 		}
-		if tenantDataSourceBack != nil && tenantDataSourceBack.Enable == 1 {
-			utils.CopyStruct(tenantDataSourceBack, &tenantDataSource)
-			if tenantDataSource.URL == "" {
-				soelog.Logger.Error("备用数据源赋值失败")
-			}
-		} else {
-			isNew = false
+		if back != nil {
+			tenantDataSource = *back
+			isBack = true
 		}
 	}
-	if !isNew || tenantDataSource.URL == "" {
-		repository := NewTDSRepository(crmDB)
-		tenantDataSource, err = repository.GetByTenantID(tenantID)
+	if !isBack {
+		tenantDataSource, err = getDataSource(ctx, provider, tenantID)
 		if err != nil {
-			return nil, err
+			return nil, tenantDataSource, false, err
 		}
 	}
-	dbName, server, port := utils.GetDBInfo(tenantDataSource.URL, tenantDataSource.DriverClassname)
-	if dbName == "" {
-		return nil, errors.New("数据源设置错误，数据库名为空！")
+	// 打开连接前检查连接预算，成功后由 storePool 转为连接池占用
+	need := connsOf(tenantDataSource)
+	if err = reserveConns(need, nil); err != nil {
+		return nil, tenantDataSource, isBack, err
 	}
-	if server == "" {
-		return nil, errors.New("数据源设置错误，数据库服务器！")
+	opened := false
+	defer func() {
+		// 打开失败（含 panic）时释放预留
+		if !opened {
+			releaseConns(need)
+		}
+	}()
+	if sqlDb, err = openDB(ctx, tenantDataSource, opt); err != nil {
+		return nil, tenantDataSource, isBack, err
 	}
+	opened = true
+	return sqlDb, tenantDataSource, isBack, nil
+}
+
+// loadBackDataSource 读取已启用的备用数据源，未配置或未启用时返回 nil
+func loadBackDataSource(ctx context.Context, tenantID string, provider TenantDataSourceProvider) (*TenantDataSource, error) {
+	tenantDataSourceBack, err := getBackDataSource(ctx, provider, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if tenantDataSourceBack == nil || tenantDataSourceBack.Enable != 1 {
+		return nil, nil
+	}
+	var tenantDataSource TenantDataSource
+	utils.CopyStruct(tenantDataSourceBack, &tenantDataSource)
+	if tenantDataSource.URL == "" {
+		soelog.Logger.Error("备用数据源赋值失败")
+		return nil, nil
+	}
+	return &tenantDataSource, nil
+}
+
+// openDataSource 根据数据源配置打开连接并设置连接池，使用 ctx 执行首次连接检查
+func openDataSource(ctx context.Context, tenantDataSource TenantDataSource, opt *OptSQL) (sqlDb *gorm.DB, err error) {
 	// DES 解密
 	data := []byte(tenantDataSource.Password)
 	password := des.DecryptDESECB(data, des.DesKey)
 	if password == "" {
 		return nil, errors.New("数据源设置错误，密码为空！")
 	}
-
-	if tenantDataSource.DriverClassname == "org.postgresql.ds.PGSimpleDataSource" {
-		dbInfo := fmt.Sprintf("host=%s user=%s port=%d dbname=%s sslmode=disable password=%s application_name=%s",
-			server, tenantDataSource.UserName, port, dbName, password, opt.ApplicationName)
-		sqlDb, err = gorm.Open(postgres.Open(dbInfo), &opt.DBConfig)
-	} else {
-		dbInfo := fmt.Sprintf("server=%s;user id=%s;password=%s;database=%s;port=%d;encrypt=disable; application_name=%s", server, tenantDataSource.UserName, password, dbName, port, opt.ApplicationName)
-		sqlDb, err = gorm.Open(sqlserver.Open(dbInfo), &opt.DBConfig)
+	dialector, driver, err := dialectorOf(tenantDataSource.URL, tenantDataSource.UserName, password, opt.ApplicationName)
+	if err != nil {
+		return nil, err
 	}
-
+	sqlDb, err = gorm.Open(dialector, gormConfig(opt))
 	if err != nil {
 		return nil, err
 	}
@@ -96,6 +145,46 @@ func _getDB(tenantID string, crmDB *gorm.DB, opt *OptSQL, enable bool) (sqlDb *g
 			return nil, err
 		}
 	}
+	db, err := sqlDb.DB()
+	if err != nil {
+		return nil, errors.New("获取租户数据库，设置连接池获取 sql.DB失败:" + err.Error())
+	}
+	setPoolSize(db, tenantDataSource)
+	if !opt.DBConfig.DisableAutomaticPing {
+		if err = db.PingContext(ctx); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
+	// 只读副本
+	if err = useReplicas(ctx, sqlDb, driver, tenantDataSource, password, opt); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return sqlDb, nil
+}
+
+// probeDataSource 使用单个连接检查数据源连通性（不经 gorm，不加载插件与只读副本）
+func probeDataSource(ctx context.Context, tenantDataSource TenantDataSource, opt *OptSQL) error {
+	password := des.DecryptDESECB([]byte(tenantDataSource.Password), des.DesKey)
+	if password == "" {
+		return errors.New("数据源设置错误，密码为空！")
+	}
+	driver, dsn, err := dsnOf(tenantDataSource.URL, tenantDataSource.UserName, password, opt.ApplicationName)
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open(sqlDriverName(driver), dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	return db.PingContext(ctx)
+}
+
+// setPoolSize 按数据源配置设置连接池
+func setPoolSize(db *sql.DB, tenantDataSource TenantDataSource) {
 	if tenantDataSource.MaxPoolSize == 0 {
 		tenantDataSource.MaxPoolSize = 10
 	}
@@ -105,24 +194,25 @@ func _getDB(tenantID string, crmDB *gorm.DB, opt *OptSQL, enable bool) (sqlDb *g
 	if tenantDataSource.ExpMinute == 0 {
 		tenantDataSource.ExpMinute = 5
 	}
-	db, err := sqlDb.DB()
-	if err != nil {
-		return nil, errors.New("获取租户数据库，设置连接池获取 sql.DB失败:" + err.Error())
-	}
 	db.SetMaxIdleConns(tenantDataSource.PoolSize)                   // 设置最大空闲连接数
 	db.SetMaxOpenConns(tenantDataSource.MaxPoolSize)                // 设置最大连接数
 	db.SetConnMaxLifetime(tenantDataSource.ExpMinute * time.Minute) // 设置连接最大生命周期
-	return sqlDb, nil
 }
 
-// GetDbFromMap 获取数据源标准方法 tenantID（租户编号）、crmDB（获取配置连接源）、args（参数列表{程序名称、启用链路、日志级别}）
-func GetDbFromMap(tenantID string, crmDB *gorm.DB, args ...interface{}) (*gorm.DB, error) {
+// getDbFromMap 从缓存获取租户数据源，未命中时创建
+func getDbFromMap(ctx context.Context, tenantID string, provider TenantDataSourceProvider, opt *OptSQL, enable bool) (*gorm.DB, error) {
 	// 启动健康检查器（仅首次调用时启动）
 	startHealthChecker()
 
 	// 第一次检查：无锁快速路径，直接从缓存获取
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
+	if pool, isOk := dbMap.Load(tenantID); isOk {
+		pool.(*tenantPool).touch()
+		return pool.(*tenantPool).db.Load(), nil
+	}
+
+	// 数据源处于失败退避窗口内，直接返回
+	if err := checkUnavailable(tenantID); err != nil {
+		return nil, err
 	}
 
 	// 缓存未命中，需要创建新连接，使用键锁避免重复创建
@@ -131,130 +221,62 @@ func GetDbFromMap(tenantID string, crmDB *gorm.DB, args ...interface{}) (*gorm.D
 	defer keylock.GetKeyLockIns().Unlock(key)
 
 	// 第二次检查：可能在等待锁期间已被其他goroutine创建
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
+	if pool, isOk := dbMap.Load(tenantID); isOk {
+		pool.(*tenantPool).touch()
+		return pool.(*tenantPool).db.Load(), nil
 	}
 
-	// 确实需要创建新连接
-	applicationName, enableTrace, logLevel := parseArguments(args...)
-	newDb, err := _getDB(tenantID, crmDB, &OptSQL{
-		DBConfig:        gorm.Config{Logger: logger.Default.LogMode(logLevel)},
-		ApplicationName: applicationName,
-		EnableTrace:     enableTrace,
-	}, false)
-
-	if err != nil {
+	// 等待锁期间 ctx 可能已结束，或其他 goroutine 刚刚创建失败
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-
-	dbMap.Store(tenantID, newDb)
-	soelog.Logger.Info(fmt.Sprintf("创建新数据源连接：租户[%s]", tenantID))
-	return newDb, nil
-}
-
-// GetDbFromMapWithOpt 根据配置获取数据源 tenantID（租户编号）、crmDB（获取配置连接源）、opt（数据库配置信息）
-func GetDbFromMapWithOpt(tenantID string, crmDB *gorm.DB, opt *OptSQL) (*gorm.DB, error) {
-	// 启动健康检查器（仅首次调用时启动）
-	startHealthChecker()
-
-	// 第一次检查：无锁快速路径，直接从缓存获取
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
-	}
-
-	// 缓存未命中，需要创建新连接，使用键锁避免重复创建
-	key := "SQLDB_" + tenantID
-	keylock.GetKeyLockIns().Lock(key)
-	defer keylock.GetKeyLockIns().Unlock(key)
-
-	// 第二次检查：可能在等待锁期间已被其他goroutine创建
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
+	if err := checkUnavailable(tenantID); err != nil {
+		return nil, err
 	}
 
 	// 确实需要创建新连接
-	newDb, err := _getDB(tenantID, crmDB, opt, false)
+	newDb, tenantDataSource, isBack, err := _getDB(ctx, tenantID, provider, opt, enable)
 	if err != nil {
+		// 连接预算不足与数据源无关，不进入失败退避
+		if !errors.Is(err, ErrConnBudgetExceeded) {
+			recordFailure(ctx, tenantID, err)
+		}
 		return nil, err
 	}
-
-	dbMap.Store(tenantID, newDb)
+	clearFailure(tenantID)
+
+	pool := &tenantPool{
+		tenantID: tenantID,
+		provider: provider,
+		opt:      opt,
+		version:  tenantDataSource.Version,
+		backup:   isBack,
+		manual:   isBack,
+	}
+	pool.db.Store(newDb)
+	storePool(pool, connsOf(tenantDataSource))
 	soelog.Logger.Info(fmt.Sprintf("创建新数据源连接：租户[%s]", tenantID))
 	return newDb, nil
 }
 
-func senMsgToWx(tenantId string, status sql.DBStats) {
-	if status.Idle == 0 {
-		go func() {
-			errMsg := fmt.Sprintf("租户：%s 数据源出现异常   最大连接：%d,打开连接：%d，使用连接：%d，等待连接：%d", tenantId, status.MaxOpenConnections, status.OpenConnections, status.InUse,
-				status.WaitCount)
-			log.Println(errMsg)
-			utils.SendMsgToWorkWx(utils.DefaultRegChatID, errMsg, utils.WorkWxAPIPath, utils.WorkWxRestTokenStr)
-		}()
-	}
+// GetDbFromMap 获取数据源标准方法 tenantID（租户编号）、provider（数据源提供者）、args（参数列表{程序名称、启用链路、日志级别}）
+func GetDbFromMap(tenantID string, provider TenantDataSourceProvider, args ...interface{}) (*gorm.DB, error) {
+	return GetDB(context.Background(), tenantID, append(argumentOptions(args...), WithProvider(provider))...)
 }
 
-// GetDbFromMapV2 获取数据源扩展方法 tenantID（租户编号）、crmDB（获取配置连接源）、enable（是否启用备库）args（参数列表{程序名称、启用链路、日志级别}）
-func GetDbFromMapV2(tenantID string, crmDB *gorm.DB, enable bool, args ...interface{}) (*gorm.DB, error) {
-	// 启动健康检查器（仅首次调用时启动）
-	startHealthChecker()
-
-	// 第一次检查：无锁快速路径，直接从缓存获取
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
-	}
-
-	// 缓存未命中，需要创建新连接，使用键锁避免重复创建
-	key := "SQLDB_" + tenantID
-	keylock.GetKeyLockIns().Lock(key)
-	defer keylock.GetKeyLockIns().Unlock(key)
-
-	// 第二次检查：可能在等待锁期间已被其他goroutine创建
-	if sqlDB, isOk := dbMap.Load(tenantID); isOk {
-		return sqlDB.(*gorm.DB), nil
-	}
-
-	// 确实需要创建新连接
-	applicationName, enableTrace, logLevel := parseArguments(args...)
-	newDb, err := _getDB(tenantID, crmDB, &OptSQL{
-		DBConfig:        gorm.Config{Logger: logger.Default.LogMode(logLevel)},
-		ApplicationName: applicationName,
-		EnableTrace:     enableTrace,
-	}, enable)
-
-	if err != nil {
-		return nil, err
-	}
-
-	dbMap.Store(tenantID, newDb)
-	soelog.Logger.Info(fmt.Sprintf("创建新数据源连接：租户[%s]", tenantID))
-	return newDb, nil
+// GetDbFromMapWithOpt 根据配置获取数据源 tenantID（租户编号）、provider（数据源提供者）、opt（数据库配置信息）
+func GetDbFromMapWithOpt(tenantID string, provider TenantDataSourceProvider, opt *OptSQL) (*gorm.DB, error) {
+	return GetDB(context.Background(), tenantID, WithProvider(provider), withOptSQL(opt))
 }
 
-func parseArguments(args ...interface{}) (string, bool, logger.LogLevel) {
-	applicationName := "go-service"
-	enableTrace := false
-	logLevel := logger.Error // 默认错误级别L
-	switch len(args) {
-	case 1:
-		applicationName = args[0].(string)
-	case 2:
-		applicationName = args[0].(string)
-		enableTrace = args[1].(bool)
-	case 3:
-		applicationName = args[0].(string)
-		enableTrace = args[1].(bool)
-		logLevel = args[2].(logger.LogLevel)
-	}
-	return applicationName, enableTrace, logLevel
+// GetDbFromMapV2 获取数据源扩展方法 tenantID（租户编号）、provider（数据源提供者）、enable（是否启用备库）args（参数列表{程序名称、启用链路、日志级别}）
+func GetDbFromMapV2(tenantID string, provider TenantDataSourceProvider, enable bool, args ...interface{}) (*gorm.DB, error) {
+	return GetDB(context.Background(), tenantID, append(argumentOptions(args...), WithProvider(provider), WithBackup(enable))...)
 }
 
 func UpdateMapV2(tenantID string) {
-	if sqlDb, isOk := dbMap.Load(tenantID); isOk {
-		db := sqlDb.(*gorm.DB)
-		sqlDb, _ := db.DB()
-		_ = sqlDb.Close()
-		dbMap.Delete(tenantID)
+	if pool, isOk := dbMap.Load(tenantID); isOk {
+		removeAndCloseDB(pool.(*tenantPool))
 	}
 }
 
@@ -279,6 +301,9 @@ func StopHealthChecker() {
 func healthCheckLoop(ctx context.Context) {
 	ticker := time.NewTicker(healthCheckInterval)
 	defer ticker.Stop()
+	// 空闲回收与健康检查共用同一个协程
+	evictTicker := time.NewTicker(evictInterval())
+	defer evictTicker.Stop()
 
 	for {
 		select {
@@ -286,6 +311,10 @@ func healthCheckLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			checkAllConnections()
+		case <-evictTicker.C:
+			evictIdlePools()
+		case <-limitChanged:
+			evictTicker.Reset(evictInterval())
 		}
 	}
 }
@@ -294,50 +323,71 @@ func healthCheckLoop(ctx context.Context) {
 func checkAllConnections() {
 	dbMap.Range(func(key, value interface{}) bool {
 		tenantID := key.(string)
-		db := value.(*gorm.DB)
-
-		// 使用带超时的context进行Ping检查
-		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
-		defer cancel()
+		pool := value.(*tenantPool)
+		db := pool.db.Load()
 
 		sqlDB, err := db.DB()
 		if err != nil {
 			soelog.Logger.Error(fmt.Sprintf("租户[%s]获取底层数据库连接失败: %v", tenantID, err))
-			removeAndCloseDB(tenantID, db)
+			removeAndCloseDB(pool)
 			return true
 		}
 
-		// 在独立goroutine中执行Ping，避免阻塞其他租户的检查
-		done := make(chan error, 1)
-		go func() {
-			done <- sqlDB.PingContext(ctx)
-		}()
-
-		select {
-		case err := <-done:
-			if err != nil {
-				soelog.Logger.Warn(fmt.Sprintf("租户[%s]连接健康检查失败，将移除缓存: %v", tenantID, err))
-				removeAndCloseDB(tenantID, db)
-				// 记录连接池状态
-				if stats := sqlDB.Stats(); stats.Idle == 0 {
-					senMsgToWx(tenantID, stats)
-				}
+		err = pingDB(sqlDB)
+		pool.recordHealth(err)
+		stats := sqlDB.Stats()
+		if err == nil {
+			checkReplicas(tenantID, db)
+			// 检查间隔内出现等待连接且无空闲连接，视为连接池耗尽
+			if stats.WaitCount > pool.waits.Swap(stats.WaitCount) && stats.Idle == 0 {
+				emitAlert(Alert{TenantID: tenantID, Kind: AlertPoolExhausted, Message: "数据源连接池已耗尽", Stats: &stats})
 			}
-		case <-ctx.Done():
-			soelog.Logger.Warn(fmt.Sprintf("租户[%s]连接健康检查超时，将移除缓存", tenantID))
-			removeAndCloseDB(tenantID, db)
+		} else {
+			emitAlert(Alert{TenantID: tenantID, Kind: AlertHealthCheckFailed, Message: "数据源出现异常：" + err.Error(), Stats: &stats})
+		}
+		if policy := pool.failoverPolicy(); policy != nil {
+			handleFailover(pool, policy, err)
+			return true
+		}
+		if err != nil {
+			soelog.Logger.Warn(fmt.Sprintf("租户[%s]连接健康检查失败，将移除缓存: %v", tenantID, err))
+			removeAndCloseDB(pool)
 		}
-
 		return true
 	})
 }
 
+// pingDB 使用带超时的context进行Ping检查
+func pingDB(sqlDB *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	// 在独立goroutine中执行Ping，避免阻塞其他租户的检查
+	done := make(chan error, 1)
+	go func() {
+		done <- sqlDB.PingContext(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.New("连接健康检查超时")
+	}
+}
+
 // removeAndCloseDB 移除并关闭数据库连接
-func removeAndCloseDB(tenantID string, db *gorm.DB) {
-	dbMap.Delete(tenantID)
+func removeAndCloseDB(pool *tenantPool) {
+	dbMap.CompareAndDelete(pool.tenantID, pool)
+	closeDB(pool.db.Load())
+}
+
+// closeDB 关闭连接池及其只读副本
+func closeDB(db *gorm.DB) {
 	if sqlDB, err := db.DB(); err == nil {
 		_ = sqlDB.Close()
 	}
+	closeReplicas(db)
 }
 
 // SetHealthCheckInterval 设置健康检查间隔（用于测试或特殊场景调整）
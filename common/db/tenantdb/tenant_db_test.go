@@ -26,7 +26,7 @@ func TestGetDbFromMapWithOpt(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db, err := GetDbFromMapWithOpt("600002", CrmDb, &OptSQL{
+	db, err := GetDbFromMapWithOpt("600002", NewCRMProvider(CrmDb), &OptSQL{
 		DBConfig:        gorm.Config{Logger: logger.Default.LogMode(logger.Info)},
 		ApplicationName: "soe-lib",
 	})
@@ -56,6 +56,8 @@ func TestConcurrentGetDbFromMap(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	provider := NewCRMProvider(CrmDb)
+
 	// 清理测试环境
 	defer func() {
 		UpdateMapV2("600002")
@@ -80,7 +82,7 @@ func TestConcurrentGetDbFromMap(t *testing.T) {
 			defer wg.Done()
 
 			reqStart := time.Now()
-			db, err := GetDbFromMap(tenantID, CrmDb, "soe-lib-test", false, logger.Error)
+			db, err := GetDbFromMap(tenantID, provider, "soe-lib-test", false, logger.Error)
 			reqDuration := time.Since(reqStart)
 			durations[index] = reqDuration
 
@@ -150,6 +152,7 @@ func BenchmarkGetDbFromMapConcurrent(b *testing.B) {
 		b.Fatal(err)
 	}
 
+	provider := NewCRMProvider(CrmDb)
 	defer func() {
 		UpdateMapV2("600002")
 		StopHealthChecker()
@@ -158,12 +161,12 @@ func BenchmarkGetDbFromMapConcurrent(b *testing.B) {
 	tenantID := "600002"
 
 	// 预热：先创建连接
-	_, _ = GetDbFromMap(tenantID, CrmDb, "soe-lib-bench", false, logger.Silent)
+	_, _ = GetDbFromMap(tenantID, provider, "soe-lib-bench", false, logger.Silent)
 
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			db, err := GetDbFromMap(tenantID, CrmDb, "soe-lib-bench", false, logger.Silent)
+			db, err := GetDbFromMap(tenantID, provider, "soe-lib-bench", false, logger.Silent)
 			if err != nil {
 				b.Errorf("获取连接失败: %v", err)
 				continue
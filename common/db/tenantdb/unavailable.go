@@ -0,0 +1,132 @@
+package tenantdb
+
+/**
+  租户数据源失败缓存：数据源未配置或连接失败时记录失败状态，按指数退避窗口直接返回 ErrTenantUnavailable，
+  避免故障期间每个请求都查询 CRM 并尝试建立连接
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+)
+
+// ErrTenantUnavailable 租户数据源不可用（处于失败退避窗口内），可用 errors.Is 判断
+var ErrTenantUnavailable = errors.New("租户数据源不可用")
+
+// TenantUnavailableError 租户数据源不可用的详细信息
+type TenantUnavailableError struct {
+	TenantID string
+	Failures int       // 连续失败次数
+	RetryAt  time.Time // 允许重试的时间
+	Cause    error     // 最近一次失败原因
+}
+
+func (e *TenantUnavailableError) Error() string {
+	return fmt.Sprintf("租户[%s]数据源不可用（连续失败 %d 次，%s 后重试）: %v", e.TenantID, e.Failures, e.RetryAt.Format("15:04:05"), e.Cause)
+}
+
+func (e *TenantUnavailableError) Is(target error) bool {
+	return target == ErrTenantUnavailable
+}
+
+func (e *TenantUnavailableError) Unwrap() error {
+	return e.Cause
+}
+
+// BackoffConfig 失败退避配置
+type BackoffConfig struct {
+	Initial time.Duration // 首次失败后的退避时间（默认5秒，小于0表示不启用失败缓存）
+	Max     time.Duration // 最大退避时间（默认5分钟）
+}
+
+var (
+	backoffConfig = BackoffConfig{Initial: 5 * time.Second, Max: 5 * time.Minute}
+	backoffMu     sync.RWMutex
+	failureMap    sync.Map // tenantID -> *tenantFailure
+)
+
+// tenantFailure 租户失败状态
+type tenantFailure struct {
+	failures int
+	retryAt  time.Time
+	lastErr  error
+}
+
+// SetBackoff 设置失败退避配置
+func SetBackoff(config BackoffConfig) {
+	if config.Initial == 0 {
+		config.Initial = 5 * time.Second
+	}
+	if config.Max <= 0 {
+		config.Max = 5 * time.Minute
+	}
+	backoffMu.Lock()
+	backoffConfig = config
+	backoffMu.Unlock()
+}
+
+func getBackoff() BackoffConfig {
+	backoffMu.RLock()
+	defer backoffMu.RUnlock()
+	return backoffConfig
+}
+
+// ClearUnavailable 清除租户失败状态，使下次请求立即重试（tenantID 为空时清除所有租户）
+func ClearUnavailable(tenantID string) {
+	if tenantID != "" {
+		failureMap.Delete(tenantID)
+		return
+	}
+	failureMap.Range(func(key, _ interface{}) bool {
+		failureMap.Delete(key)
+		return true
+	})
+}
+
+// checkUnavailable 租户处于退避窗口内时返回 TenantUnavailableError
+func checkUnavailable(tenantID string) error {
+	value, ok := failureMap.Load(tenantID)
+	if !ok {
+		return nil
+	}
+	failure := value.(tenantFailure)
+	if !time.Now().Before(failure.retryAt) {
+		return nil
+	}
+	return &TenantUnavailableError{TenantID: tenantID, Failures: failure.failures, RetryAt: failure.retryAt, Cause: failure.lastErr}
+}
+
+// recordFailure 记录租户失败并计算下一次退避窗口，调用方取消或超时不计入失败
+func recordFailure(ctx context.Context, tenantID string, err error) {
+	if ctx.Err() != nil || errors.Is(err, ErrConnBudgetExceeded) {
+		return
+	}
+	config := getBackoff()
+	if config.Initial < 0 {
+		return
+	}
+	failure := tenantFailure{failures: 1, lastErr: err}
+	if value, ok := failureMap.Load(tenantID); ok {
+		failure.failures = value.(tenantFailure).failures + 1
+	}
+	backoff := config.Initial
+	for i := 1; i < failure.failures && backoff < config.Max; i++ {
+		backoff *= 2
+	}
+	if backoff > config.Max {
+		backoff = config.Max
+	}
+	failure.retryAt = time.Now().Add(backoff)
+	failureMap.Store(tenantID, failure)
+	soelog.Logger.Warn(fmt.Sprintf("租户[%s]数据源获取失败(连续 %d 次)，%v 内不再重试: %v", tenantID, failure.failures, backoff, err))
+}
+
+// clearFailure 连接成功后清除失败状态
+func clearFailure(tenantID string) {
+	failureMap.Delete(tenantID)
+}
@@ -0,0 +1,46 @@
+package tenantdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+)
+
+func TestUnavailable(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	SetBackoff(BackoffConfig{Initial: time.Minute, Max: 3 * time.Minute})
+	defer SetBackoff(BackoffConfig{})
+
+	cause := errors.New("数据源未配置！")
+	recordFailure(context.Background(), "1001", cause)
+	err := checkUnavailable("1001")
+	if !errors.Is(err, ErrTenantUnavailable) || !errors.Is(err, cause) {
+		t.Fatalf("期望 ErrTenantUnavailable，实际 %v", err)
+	}
+
+	// 指数退避，不超过最大值
+	recordFailure(context.Background(), "1001", cause)
+	recordFailure(context.Background(), "1001", cause)
+	var unavailable *TenantUnavailableError
+	if !errors.As(checkUnavailable("1001"), &unavailable) || unavailable.Failures != 3 ||
+		time.Until(unavailable.RetryAt) > 3*time.Minute || time.Until(unavailable.RetryAt) < 2*time.Minute {
+		t.Errorf("退避时间错误: %+v", unavailable)
+	}
+
+	// 调用方取消不计入失败
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	recordFailure(ctx, "1002", context.Canceled)
+	if err := checkUnavailable("1002"); err != nil {
+		t.Errorf("取消的请求不应记录失败: %v", err)
+	}
+
+	ClearUnavailable("")
+	if err := checkUnavailable("1001"); err != nil {
+		t.Errorf("清除后应允许重试: %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package tenantdb
+
+/**
+  数据源热更新：监听数据源版本（TenantDataSource.Version）变化，创建新连接池替换缓存，旧连接池延迟关闭
+  变更来源：定时轮询（CRM 等任意提供者）、Redis 发布订阅、Nacos（StaticProvider）
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/soedev/soelib/common/keylock"
+	"github.com/soedev/soelib/common/soelog"
+)
+
+// VersionWatcher 数据源变更监听
+type VersionWatcher interface {
+	// Watch 阻塞监听数据源变更直到 ctx 结束，变更时调用 onChange（tenantID 为空表示检查所有已缓存租户）
+	Watch(ctx context.Context, onChange func(tenantID string)) error
+}
+
+// StartWatcher 启动数据源变更监听，变更时自动热更新连接池
+func StartWatcher(ctx context.Context, watcher VersionWatcher) {
+	go func() {
+		err := watcher.Watch(ctx, func(tenantID string) {
+			if tenantID == "" {
+				ReloadAll()
+				return
+			}
+			// 数据源变更后允许立即重试
+			ClearUnavailable(tenantID)
+			if _, err := ReloadTenant(tenantID); err != nil {
+				soelog.Logger.Warn(fmt.Sprintf("租户[%s]数据源热更新失败: %v", tenantID, err))
+			}
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			soelog.Logger.Error(fmt.Sprintf("租户数据源变更监听已退出: %v", err))
+		}
+	}()
+}
+
+// ReloadAll 检查所有已缓存租户的数据源版本，版本变化时热更新
+func ReloadAll() {
+	dbMap.Range(func(key, _ interface{}) bool {
+		tenantID := key.(string)
+		if _, err := ReloadTenant(tenantID); err != nil {
+			soelog.Logger.Warn(fmt.Sprintf("租户[%s]数据源热更新失败: %v", tenantID, err))
+		}
+		return true
+	})
+}
+
+// ReloadTenant 检查租户数据源版本，版本变化时创建新连接池并替换（连接预算不足时返回 ErrConnBudgetExceeded），返回是否已替换
+func ReloadTenant(tenantID string) (bool, error) {
+	value, isOk := dbMap.Load(tenantID)
+	if !isOk {
+		return false, nil
+	}
+	pool := value.(*tenantPool)
+
+	key := "SQLDB_" + tenantID
+	keylock.GetKeyLockIns().Lock(key)
+	defer keylock.GetKeyLockIns().Unlock(key)
+
+	pool.mu.Lock()
+	backup, version := pool.backup, pool.version
+	pool.mu.Unlock()
+
+	// 读取配置与建立连接不持有 pool.mu，受 healthCheckTimeout 限制
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	// 按当前使用的主/备数据源读取最新配置
+	var tenantDataSource TenantDataSource
+	if backup {
+		back, err := loadBackDataSource(ctx, tenantID, pool.provider)
+		if err != nil {
+			return false, err
+		}
+		if back == nil {
+			return false, nil
+		}
+		tenantDataSource = *back
+	} else {
+		var err error
+		if tenantDataSource, err = getDataSource(ctx, pool.provider, tenantID); err != nil {
+			return false, err
+		}
+	}
+	if tenantDataSource.Version == version {
+		return false, nil
+	}
+
+	// 新连接池计入连接预算，替换后旧连接池延迟关闭期间按打开的连接数计入
+	newDb, release, err := openReserved(ctx, pool, tenantDataSource)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	sqlDB, err := newDb.DB()
+	if err != nil {
+		closeDB(newDb)
+		return false, err
+	}
+	if err = pingDB(sqlDB); err != nil {
+		closeDB(newDb)
+		return false, errors.New("新数据源连接检查失败:" + err.Error())
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	// 建立连接期间连接池可能已被回收、移除或主备切换
+	if pool.backup != backup || pool.version != version || !pool.cached() {
+		closeDB(newDb)
+		return false, nil
+	}
+	soelog.Logger.Info(fmt.Sprintf("租户[%s]数据源版本 %d -> %d，已替换连接池", tenantID, version, tenantDataSource.Version))
+	pool.swap(newDb, tenantDataSource.Version, backup)
+	return true, nil
+}
+
+// PollWatcher 定时轮询数据源提供者（如 CRM 数据表）检查版本变化
+type PollWatcher struct {
+	Interval time.Duration // 轮询间隔（默认1分钟）
+}
+
+func (w PollWatcher) Watch(ctx context.Context, onChange func(tenantID string)) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			onChange("")
+		}
+	}
+}
+
+// RedisWatcher 订阅 Redis 频道，消息内容为租户号（为空或 * 表示全部租户）
+// 定时 PING 检测半开连接（LB 空闲超时、Redis 主从切换），超时未收到回复时重连；建议 Pool 设置 redis.DialWriteTimeout
+type RedisWatcher struct {
+	Pool    *redis.Pool
+	Channel string // 订阅频道，默认 DefaultReloadChannel
+}
+
+// DefaultReloadChannel 默认数据源变更通知频道
+const DefaultReloadChannel = "soelib:tenantdb:reload"
+
+func (w RedisWatcher) channel() string {
+	if w.Channel == "" {
+		return DefaultReloadChannel
+	}
+	return w.Channel
+}
+
+func (w RedisWatcher) Watch(ctx context.Context, onChange func(tenantID string)) error {
+	if w.Pool == nil {
+		return errors.New("redis 连接池未初始化")
+	}
+	backoff := time.Second
+	for {
+		err := w.subscribe(ctx, onChange)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		soelog.Logger.Warn(fmt.Sprintf("数据源变更订阅断开，%v 后重连: %v", backoff, err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// watcherHealthCheck 订阅连接的心跳间隔，超过两倍间隔未收到任何回复视为断开（半开连接）并重连
+var watcherHealthCheck = 30 * time.Second
+
+func (w RedisWatcher) subscribe(ctx context.Context, onChange func(tenantID string)) error {
+	psc := redis.PubSubConn{Conn: w.Pool.Get()}
+	if err := psc.Subscribe(w.channel()); err != nil {
+		_ = psc.Close()
+		return err
+	}
+	// 写命令在独立协程中发送：定时 PING 检测半开连接，ctx 结束时退订使 Receive 返回
+	done := make(chan struct{})
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		ticker := time.NewTicker(watcherHealthCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = psc.Unsubscribe()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	// 关闭连接前等待写协程退出；写命令阻塞（半开连接）时最多等待一个心跳间隔，之后由写协程退出时关闭连接
+	defer func() {
+		close(done)
+		select {
+		case <-written:
+			_ = psc.Close()
+		case <-time.After(watcherHealthCheck):
+			go func() {
+				<-written
+				_ = psc.Close()
+			}()
+		}
+	}()
+	for {
+		switch v := psc.ReceiveWithTimeout(2 * watcherHealthCheck).(type) {
+		case redis.Message:
+			tenantID := strings.TrimSpace(string(v.Data))
+			if tenantID == "*" {
+				tenantID = ""
+			}
+			onChange(tenantID)
+		case redis.Subscription:
+			if v.Count == 0 {
+				return ctx.Err()
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// PublishReload 发布数据源变更通知（tenantID 为空表示全部租户），channel 为空时使用 DefaultReloadChannel
+func PublishReload(pool *redis.Pool, channel, tenantID string) error {
+	if channel == "" {
+		channel = DefaultReloadChannel
+	}
+	conn := pool.Get()
+	defer conn.Close()
+	if tenantID == "" {
+		tenantID = "*"
+	}
+	_, err := conn.Do("PUBLISH", channel, tenantID)
+	return err
+}
@@ -0,0 +1,225 @@
+package tenantdb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/soedev/soelib/common/soelog"
+	"go.uber.org/zap"
+)
+
+func TestReloadTenant(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	d := useFakeDB(t)
+
+	provider := NewStaticProvider([]DataSourceConfig{{TenantID: 9301, Version: 1, URL: "reload-v1"}})
+	if _, err := getDbFromMap(context.Background(), "9301", provider, &OptSQL{}, false); err != nil {
+		t.Fatal(err)
+	}
+	defer UpdateMapV2("9301")
+
+	if reloaded, err := ReloadTenant("9301"); err != nil || reloaded {
+		t.Fatalf("版本未变化不应替换: %v, %v", reloaded, err)
+	}
+	if err := provider.Load([]byte(`[{"tenantId":9301,"version":2,"url":"reload-v2"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := ReloadTenant("9301"); err != nil || !reloaded {
+		t.Fatalf("版本变化应替换: %v, %v", reloaded, err)
+	}
+	if stats, _ := TenantStats("9301"); stats.Version != 2 {
+		t.Fatalf("版本 = %d, want 2", stats.Version)
+	}
+
+	// 新连接池超过连接预算或连接失败时不替换，释放预留的预算
+	SetPoolLimit(PoolLimitConfig{MaxTotalConns: 25})
+	defer SetPoolLimit(PoolLimitConfig{})
+	if err := provider.Load([]byte(`[{"tenantId":9301,"version":3,"url":"reload-v3","maxPoolSize":30}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReloadTenant("9301"); !errors.Is(err, ErrConnBudgetExceeded) {
+		t.Fatalf("期望 ErrConnBudgetExceeded，实际 %v", err)
+	}
+	d.setDown("reload-v4", true)
+	if err := provider.Load([]byte(`[{"tenantId":9301,"version":4,"url":"reload-v4"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := ReloadTenant("9301"); err == nil || reloaded {
+		t.Fatalf("连接失败不应替换: %v, %v", reloaded, err)
+	}
+	if stats, _ := TenantStats("9301"); stats.Version != 2 || reservedConns != 0 {
+		t.Fatalf("版本 = %d, 预留 %d", stats.Version, reservedConns)
+	}
+}
+
+func TestRedisWatcher(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	mr := miniredis.RunT(t)
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", mr.Addr()) }}
+	defer pool.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changed := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- RedisWatcher{Pool: pool}.Watch(ctx, func(tenantID string) { changed <- tenantID })
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(mr.PubSubChannels("")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := PublishReload(pool, "", "9401"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tenantID := <-changed:
+		if tenantID != "9401" {
+			t.Fatalf("租户号 = %s", tenantID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("未收到变更通知")
+	}
+
+	// ctx 结束后退订并关闭连接
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Watch 返回 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ctx 结束后 Watch 未返回")
+	}
+}
+
+// freezeProxy 转发到 redis 的 TCP 代理，freeze 后已建立的连接丢弃数据但不断开（模拟半开连接）
+type freezeProxy struct {
+	ln     net.Listener
+	target string
+	mu     sync.Mutex
+	frozen []*atomic.Bool
+}
+
+func newFreezeProxy(t *testing.T, target string) *freezeProxy {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &freezeProxy{ln: ln, target: target}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			client, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			server, err := net.Dial("tcp", target)
+			if err != nil {
+				_ = client.Close()
+				continue
+			}
+			t.Cleanup(func() {
+				_ = client.Close()
+				_ = server.Close()
+			})
+			frozen := &atomic.Bool{}
+			p.mu.Lock()
+			p.frozen = append(p.frozen, frozen)
+			p.mu.Unlock()
+			go forward(server, client, frozen)
+			go forward(client, server, frozen)
+		}
+	}()
+	return p
+}
+
+func forward(dst, src net.Conn, frozen *atomic.Bool) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if err != nil {
+			return
+		}
+		if !frozen.Load() {
+			_, _ = dst.Write(buf[:n])
+		}
+	}
+}
+
+// freeze 冻结已建立的连接，之后新建的连接正常转发
+func (p *freezeProxy) freeze() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, frozen := range p.frozen {
+		frozen.Store(true)
+	}
+}
+
+func TestRedisWatcherHalfOpen(t *testing.T) {
+	soelog.Logger = zap.NewNop()
+	healthCheck := watcherHealthCheck
+	watcherHealthCheck = 50 * time.Millisecond
+	defer func() { watcherHealthCheck = healthCheck }()
+
+	mr := miniredis.RunT(t)
+	proxy := newFreezeProxy(t, mr.Addr())
+	pool := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", proxy.ln.Addr().String()) }}
+	defer pool.Close()
+	direct := &redis.Pool{Dial: func() (redis.Conn, error) { return redis.Dial("tcp", mr.Addr()) }}
+	defer direct.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- RedisWatcher{Pool: pool}.Watch(ctx, func(tenantID string) { changed <- tenantID })
+	}()
+	waitSubscribers := func(n int) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for mr.PubSubNumSub(DefaultReloadChannel)[DefaultReloadChannel] < n {
+			if time.Now().After(deadline) {
+				t.Fatalf("订阅数未达到 %d", n)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitSubscribers(1)
+
+	// 连接半开后心跳超时，重新订阅
+	proxy.freeze()
+	waitSubscribers(2)
+	if err := PublishReload(direct, "", "9501"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case tenantID := <-changed:
+		if tenantID != "9501" {
+			t.Fatalf("租户号 = %s", tenantID)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("重连后未收到变更通知")
+	}
+
+	// 半开连接上退订无回复，Watch 仍能返回
+	proxy.freeze()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Watch 返回 %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("半开连接上 ctx 结束后 Watch 未返回")
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+/**
+  jdbc   JDBC 连接串解析
+*/
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// JDBC 数据库类型
+const (
+	JDBCPostgres  = "postgresql"
+	JDBCSQLServer = "sqlserver"
+	JDBCMySQL     = "mysql"
+)
+
+// JDBCInfo JDBC 连接串解析结果
+type JDBCInfo struct {
+	Driver     string            // 数据库类型：postgresql、sqlserver、mysql
+	Host       string            // 服务器（多主机时为第一个）
+	Port       int               // 端口，未指定时为默认端口（SQL Server 指定实例名时为 0）
+	Database   string            // 数据库名
+	Instance   string            // SQL Server 实例名
+	Properties map[string]string // 连接属性（encrypt、sslmode、instanceName、超时等），保持原始大小写
+}
+
+// Property 读取连接属性（忽略大小写）
+func (i *JDBCInfo) Property(key string) string {
+	if v, ok := i.Properties[key]; ok {
+		return v
+	}
+	for k, v := range i.Properties {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseJDBCURL 解析 JDBC 连接串
+//
+//	jdbc:postgresql://host[:port]/database[?property=value[&property=value]]
+//	jdbc:mysql://host[:port]/database[?property=value[&property=value]]
+//	jdbc:sqlserver://[host[\instanceName][:port]][;property=value[;property=value]]
+func ParseJDBCURL(jdbcURL string) (*JDBCInfo, error) {
+	jdbcURL = strings.TrimSpace(jdbcURL)
+	if !strings.HasPrefix(strings.ToLower(jdbcURL), "jdbc:") {
+		return nil, fmt.Errorf("无效的 JDBC 连接串：%s", jdbcURL)
+	}
+	rest := jdbcURL[len("jdbc:"):]
+	idx := strings.Index(rest, "://")
+	if idx <= 0 {
+		return nil, fmt.Errorf("无效的 JDBC 连接串：%s", jdbcURL)
+	}
+	info := &JDBCInfo{
+		Driver:     strings.ToLower(rest[:idx]),
+		Properties: map[string]string{},
+	}
+	rest = rest[idx+len("://"):]
+
+	var err error
+	switch info.Driver {
+	case JDBCSQLServer:
+		err = parseSQLServerURL(info, rest)
+	case JDBCPostgres, JDBCMySQL:
+		err = parseStandardURL(info, rest)
+	default:
+		return nil, fmt.Errorf("不支持的 JDBC 数据库类型：%s", info.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Host == "" {
+		return nil, errors.New("JDBC 连接串缺少服务器地址")
+	}
+	return info, nil
+}
+
+// parseStandardURL 解析 postgresql/mysql 格式：host[:port][,host2[:port]]/database?k=v&k=v
+func parseStandardURL(info *JDBCInfo, rest string) error {
+	query := ""
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	hostPart := rest
+	if i := strings.Index(rest, "/"); i >= 0 {
+		hostPart, info.Database = rest[:i], rest[i+1:]
+	}
+	if database, err := url.PathUnescape(info.Database); err == nil {
+		info.Database = database
+	}
+	// 多主机时取第一个
+	if i := strings.Index(hostPart, ","); i >= 0 {
+		hostPart = hostPart[:i]
+	}
+	host, port, err := splitHostPort(hostPart)
+	if err != nil {
+		return err
+	}
+	info.Host, info.Port = host, port
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return errors.New("JDBC 连接属性解析失败:" + err.Error())
+		}
+		for k := range values {
+			info.Properties[k] = values.Get(k)
+		}
+	}
+	if info.Port == 0 {
+		if info.Driver == JDBCMySQL {
+			info.Port = 3306
+		} else {
+			info.Port = 5432
+		}
+	}
+	return nil
+}
+
+// parseSQLServerURL 解析 sqlserver 格式：host[\instance][:port];k=v;k=v
+func parseSQLServerURL(info *JDBCInfo, rest string) error {
+	parts := strings.Split(rest, ";")
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("JDBC 连接属性格式错误：%s", part)
+		}
+		info.Properties[strings.TrimSpace(kv[0])] = strings.Trim(strings.TrimSpace(kv[1]), "{}")
+	}
+	hostPart := parts[0]
+	if i := strings.Index(hostPart, `\`); i >= 0 {
+		// host\instance[:port]
+		instance := hostPart[i+1:]
+		hostPart = hostPart[:i]
+		if j := strings.LastIndex(instance, ":"); j >= 0 {
+			hostPart += instance[j:]
+			instance = instance[:j]
+		}
+		info.Instance = instance
+	}
+	host, port, err := splitHostPort(hostPart)
+	if err != nil {
+		return err
+	}
+	info.Host, info.Port = host, port
+	if info.Host == "" {
+		info.Host = info.Property("serverName")
+	}
+	if portStr := info.Property("portNumber"); info.Port == 0 && portStr != "" {
+		if info.Port, err = strconv.Atoi(portStr); err != nil {
+			return fmt.Errorf("JDBC 端口错误：%s", portStr)
+		}
+	}
+	if info.Instance == "" {
+		info.Instance = info.Property("instanceName")
+	}
+	info.Database = info.Property("databaseName")
+	if info.Database == "" {
+		info.Database = info.Property("database")
+	}
+	// 指定实例名时由 SQL Server Browser 解析端口
+	if info.Port == 0 && info.Instance == "" {
+		info.Port = 1433
+	}
+	return nil
+}
+
+// splitHostPort 拆分 host[:port]，支持 IPv6 [::1]:port
+func splitHostPort(hostPort string) (string, int, error) {
+	hostPort = strings.TrimSpace(hostPort)
+	host, portStr := hostPort, ""
+	if strings.HasPrefix(hostPort, "[") {
+		end := strings.Index(hostPort, "]")
+		if end < 0 {
+			return "", 0, fmt.Errorf("JDBC 服务器地址错误：%s", hostPort)
+		}
+		host = hostPort[1:end]
+		portStr = strings.TrimPrefix(hostPort[end+1:], ":")
+	} else if i := strings.LastIndex(hostPort, ":"); i >= 0 {
+		host, portStr = hostPort[:i], hostPort[i+1:]
+	}
+	if portStr == "" {
+		return host, 0, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("JDBC 端口错误：%s", portStr)
+	}
+	return host, port, nil
+}
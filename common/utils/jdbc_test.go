@@ -0,0 +1,50 @@
+package utils
+
+import "testing"
+
+func TestParseJDBCURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		driver   string
+		host     string
+		port     int
+		database string
+		instance string
+		props    map[string]string
+	}{
+		{"jdbc:postgresql://192.168.1.141:9999/MERCURYDB", JDBCPostgres, "192.168.1.141", 9999, "MERCURYDB", "", nil},
+		{"jdbc:postgresql://pg.soe.local/soedb?sslmode=require&connectTimeout=10", JDBCPostgres, "pg.soe.local", 5432, "soedb", "",
+			map[string]string{"sslmode": "require", "connectTimeout": "10"}},
+		{"jdbc:sqlserver://192.168.1.141:1433;databaseName=MERCURYDB", JDBCSQLServer, "192.168.1.141", 1433, "MERCURYDB", "", nil},
+		{"jdbc:sqlserver://192.168.1.141;databaseName=MERCURYDB;encrypt=true;loginTimeout=30", JDBCSQLServer, "192.168.1.141", 1433, "MERCURYDB", "",
+			map[string]string{"encrypt": "true", "loginTimeout": "30"}},
+		{`jdbc:sqlserver://192.168.1.141\SOE;databaseName=MERCURYDB`, JDBCSQLServer, "192.168.1.141", 0, "MERCURYDB", "SOE", nil},
+		{"jdbc:sqlserver://192.168.1.141;instanceName=SOE;DatabaseName=MERCURYDB", JDBCSQLServer, "192.168.1.141", 0, "MERCURYDB", "SOE", nil},
+		{"jdbc:mysql://[::1]:3307/soedb?useSSL=false&serverTimezone=Asia/Shanghai", JDBCMySQL, "::1", 3307, "soedb", "",
+			map[string]string{"useSSL": "false", "serverTimezone": "Asia/Shanghai"}},
+	}
+	for _, tt := range tests {
+		info, err := ParseJDBCURL(tt.url)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if info.Driver != tt.driver || info.Host != tt.host || info.Port != tt.port || info.Database != tt.database || info.Instance != tt.instance {
+			t.Errorf("%s: 解析结果错误 %+v", tt.url, info)
+		}
+		for k, v := range tt.props {
+			if info.Property(k) != v {
+				t.Errorf("%s: 属性 %s=%s，期望 %s", tt.url, k, info.Property(k), v)
+			}
+		}
+	}
+
+	for _, url := range []string{"", "postgresql://127.0.0.1/db", "jdbc:oracle:thin:@127.0.0.1:1521:orcl", "jdbc:postgresql://127.0.0.1:abc/db"} {
+		if _, err := ParseJDBCURL(url); err == nil {
+			t.Errorf("%s: 期望解析失败", url)
+		}
+	}
+
+	if dbName, server, port := GetDBInfo("jdbc:sqlserver://10.0.0.1", ""); dbName != "" || server != "10.0.0.1" || port != 1433 {
+		t.Errorf("GetDBInfo 缺少端口时解析错误: %s %s %d", dbName, server, port)
+	}
+}
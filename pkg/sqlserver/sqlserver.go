@@ -203,11 +203,9 @@ func (dialector Dialector) DataTypeOf(field *schema.Field) string {
 }
 
 func (dialectopr Dialector) SavePoint(tx *gorm.DB, name string) error {
-	tx.Exec("SAVE TRANSACTION " + name)
-	return nil
+	return tx.Exec("SAVE TRANSACTION " + name).Error
 }
 
 func (dialectopr Dialector) RollbackTo(tx *gorm.DB, name string) error {
-	tx.Exec("ROLLBACK TRANSACTION " + name)
-	return nil
+	return tx.Exec("ROLLBACK TRANSACTION " + name).Error
 }